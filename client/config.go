@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"dockerator/docker"
+)
+
+// config - agent settings, flags override env which overrides config file
+type config struct {
+	Server            servers  `json:"server"`
+	NodeName          string   `json:"node_name"`
+	NodeIP            string   `json:"node_ip"`
+	Interface         string   `json:"interface"`
+	PollInterval      duration `json:"poll_interval"`
+	HeartbeatInterval duration `json:"heartbeat_interval"`
+	ResyncInterval    duration `json:"resync_interval"`
+	DockerHost        string   `json:"docker_host"`
+	MaxBackoff        duration `json:"max_backoff"`
+	ReportUnmanaged   bool     `json:"report_unmanaged"`
+	JoinToken         string   `json:"join_token"`
+	CACert            string   `json:"ca_cert"`
+	SecretsDir        string   `json:"secrets_dir"`
+	ConfigsDir        string   `json:"configs_dir"`
+	StatsInterval     duration `json:"stats_interval"`
+	MetricsAddr       string   `json:"metrics_addr"`
+}
+
+// duration - time.Duration decoded from "5s" strings
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(b, &s); err != nil {
+		return
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return
+}
+
+// servers - gRPC addresses of control plane members, comma separated in flag and env
+type servers []string
+
+func (s *servers) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *servers) Set(v string) error {
+	*s = nil
+	for _, addr := range strings.Split(v, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			*s = append(*s, addr)
+		}
+	}
+	if len(*s) == 0 {
+		return fmt.Errorf("no server address")
+	}
+	return nil
+}
+
+// UnmarshalJSON - list of addresses or single comma separated string
+func (s *servers) UnmarshalJSON(b []byte) error {
+	var list []string
+	if err := json.Unmarshal(b, &list); err == nil {
+		return s.Set(strings.Join(list, ","))
+	}
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return s.Set(v)
+}
+
+var cfg = config{
+	Server:            servers{"172.17.0.1:50051"},
+	Interface:         "eth0",
+	PollInterval:      duration{5 * time.Second},
+	HeartbeatInterval: duration{5 * time.Second},
+	ResyncInterval:    duration{time.Minute},
+	MaxBackoff:        duration{time.Minute},
+	SecretsDir:        "/run/dockerator/secrets",
+	ConfigsDir:        "/var/lib/dockerator/configs",
+	StatsInterval:     duration{15 * time.Second},
+	MetricsAddr:       ":1221",
+}
+
+// loadConfig - fill cfg from config file, DOCKERATOR_* env and flags
+func loadConfig() {
+	configFile := flag.String("config", os.Getenv("DOCKERATOR_CONFIG"), "path to json config file")
+	flag.Var(&cfg.Server, "server", "server gRPC addresses, comma separated, agent fails over between them")
+	flag.StringVar(&cfg.NodeName, "node-name", cfg.NodeName, "node name reported to server (default advertised IP)")
+	flag.StringVar(&cfg.NodeIP, "node-ip", cfg.NodeIP, "advertised node IP (default first address of -interface)")
+	flag.StringVar(&cfg.Interface, "interface", cfg.Interface, "network interface to detect node IP")
+	flag.DurationVar(&cfg.PollInterval.Duration, "poll-interval", cfg.PollInterval.Duration, "interval of task polling")
+	flag.DurationVar(&cfg.HeartbeatInterval.Duration, "heartbeat-interval", cfg.HeartbeatInterval.Duration, "interval of heartbeats")
+	flag.DurationVar(&cfg.ResyncInterval.Duration, "resync-interval", cfg.ResyncInterval.Duration, "interval of full containers resync, transitions are reported from Docker events")
+	flag.DurationVar(&cfg.MaxBackoff.Duration, "max-backoff", cfg.MaxBackoff.Duration, "max delay between retries while server is unreachable")
+	flag.StringVar(&cfg.DockerHost, "docker-host", cfg.DockerHost, "Docker endpoint (default from DOCKER_HOST env)")
+	flag.StringVar(&cfg.JoinToken, "join-token", cfg.JoinToken, "token issued by server (POST /nodes/tokens) to register node")
+	flag.StringVar(&cfg.CACert, "ca-cert", cfg.CACert, "path to cluster CA certificate (GET /nodes/ca on server)")
+	flag.StringVar(&cfg.SecretsDir, "secrets-dir", cfg.SecretsDir, "host directory for secret files of containers, should be tmpfs")
+	flag.StringVar(&cfg.ConfigsDir, "configs-dir", cfg.ConfigsDir, "host directory for config files of containers")
+	flag.DurationVar(&cfg.StatsInterval.Duration, "stats-interval", cfg.StatsInterval.Duration, "interval of container stats collection")
+	flag.StringVar(&cfg.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "address of Prometheus metrics endpoint, empty disables it")
+	flag.BoolVar(&cfg.ReportUnmanaged, "report-unmanaged", cfg.ReportUnmanaged, "also list containers not created by dockerator on server")
+	flag.Parse()
+
+	// flags write straight into cfg, keep explicit ones to reapply over file and env
+	set := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			log.Fatalf("failed to read config: %v", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			log.Fatalf("failed to parse config: %v", err)
+		}
+	}
+	envs := map[string]string{
+		"server":             "DOCKERATOR_SERVER",
+		"node-name":          "DOCKERATOR_NODE_NAME",
+		"node-ip":            "DOCKERATOR_NODE_IP",
+		"interface":          "DOCKERATOR_INTERFACE",
+		"poll-interval":      "DOCKERATOR_POLL_INTERVAL",
+		"heartbeat-interval": "DOCKERATOR_HEARTBEAT_INTERVAL",
+		"resync-interval":    "DOCKERATOR_RESYNC_INTERVAL",
+		"docker-host":        "DOCKERATOR_DOCKER_HOST",
+		"max-backoff":        "DOCKERATOR_MAX_BACKOFF",
+		"report-unmanaged":   "DOCKERATOR_REPORT_UNMANAGED",
+		"join-token":         "DOCKERATOR_JOIN_TOKEN",
+		"ca-cert":            "DOCKERATOR_CA_CERT",
+		"secrets-dir":        "DOCKERATOR_SECRETS_DIR",
+		"configs-dir":        "DOCKERATOR_CONFIGS_DIR",
+		"stats-interval":     "DOCKERATOR_STATS_INTERVAL",
+		"metrics-addr":       "DOCKERATOR_METRICS_ADDR",
+	}
+	for name, env := range envs {
+		if v, ok := os.LookupEnv(env); ok {
+			if err := flag.Set(name, v); err != nil {
+				log.Fatalf("wrong %v: %v", env, err)
+			}
+		}
+	}
+	for name, v := range set {
+		flag.Set(name, v)
+	}
+
+	if cfg.NodeIP == "" {
+		cfg.NodeIP = docker.GetNodeIP(cfg.Interface)
+	}
+	if cfg.NodeName == "" {
+		cfg.NodeName = cfg.NodeIP
+	}
+	if cfg.NodeName == "" {
+		log.Fatalf("can't detect node IP on %v, set -node-ip or -node-name", cfg.Interface)
+	}
+	if cfg.JoinToken == "" {
+		log.Fatal("join token is required, set -join-token")
+	}
+	if cfg.CACert == "" {
+		log.Fatal("CA certificate is required, set -ca-cert")
+	}
+	loadCA()
+	if cfg.DockerHost != "" {
+		docker.SetHost(cfg.DockerHost)
+	}
+	docker.SetSecretsDir(cfg.SecretsDir)
+	docker.SetConfigsDir(cfg.ConfigsDir)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCAFile - PEM file of self-signed certificate accepted as -ca-cert
+func testCAFile(t *testing.T) string {
+	config, _ := testServerTLS(t)
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: config.Certificates[0].Certificate[0]})
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	ca := testCAFile(t)
+	defaults, args := cfg, os.Args
+	defer func() { cfg, os.Args = defaults, args }()
+
+	for _, c := range []struct {
+		name     string
+		file     string
+		env      map[string]string
+		flags    []string
+		server   string
+		poll     time.Duration
+		reportUM bool
+	}{
+		{name: "defaults", server: "172.17.0.1:50051", poll: 5 * time.Second},
+		{name: "file", file: `{"server":["a:50051","b:50051"],"poll_interval":"7s","report_unmanaged":true}`,
+			server: "a:50051,b:50051", poll: 7 * time.Second, reportUM: true},
+		{name: "file with comma separated servers", file: `{"server":"a:50051, b:50051"}`,
+			server: "a:50051,b:50051", poll: 5 * time.Second},
+		{name: "env over file", file: `{"server":["a:50051"],"poll_interval":"7s","report_unmanaged":true}`,
+			env:    map[string]string{"DOCKERATOR_SERVER": "e:50051,f:50051", "DOCKERATOR_REPORT_UNMANAGED": "false"},
+			server: "e:50051,f:50051", poll: 7 * time.Second},
+		{name: "flag over env and file", file: `{"server":["a:50051"],"poll_interval":"7s"}`,
+			env:    map[string]string{"DOCKERATOR_SERVER": "e:50051", "DOCKERATOR_POLL_INTERVAL": "8s"},
+			flags:  []string{"-server", "f:50051", "-poll-interval", "9s", "-report-unmanaged"},
+			server: "f:50051", poll: 9 * time.Second, reportUM: true},
+		{name: "env without file", env: map[string]string{"DOCKERATOR_POLL_INTERVAL": "8s"},
+			server: "172.17.0.1:50051", poll: 8 * time.Second},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg = defaults
+			cfg.Server = append(servers{}, defaults.Server...)
+			flag.CommandLine = flag.NewFlagSet("client", flag.ExitOnError)
+			os.Args = append([]string{"client", "-node-name", "n1", "-join-token", "t", "-ca-cert", ca}, c.flags...)
+			if c.file != "" {
+				file := filepath.Join(t.TempDir(), "config.json")
+				if err := os.WriteFile(file, []byte(c.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("DOCKERATOR_CONFIG", file)
+			}
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			loadConfig()
+			if got := cfg.Server.String(); got != c.server {
+				t.Errorf("server = %v, want %v", got, c.server)
+			}
+			if cfg.PollInterval.Duration != c.poll {
+				t.Errorf("poll interval = %v, want %v", cfg.PollInterval.Duration, c.poll)
+			}
+			if cfg.ReportUnmanaged != c.reportUM {
+				t.Errorf("report unmanaged = %v, want %v", cfg.ReportUnmanaged, c.reportUM)
+			}
+			if cfg.NodeName != "n1" {
+				t.Errorf("node name = %v", cfg.NodeName)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	pb "dockerator/dockerator"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	backoffBase     = time.Second
+	reportBufferMax = 1000
+)
+
+// rpc - connection to server, replaced when node certificate changes
+var rpc = struct {
+	sync.Mutex
+	conn   *grpc.ClientConn
+	client pb.DockeratorClient
+}{}
+
+// online - server reachability as seen by last RPC
+var online = struct {
+	sync.Mutex
+	up bool
+}{up: true}
+
+// reports - latest container report per container which failed to send while server was unreachable
+var reports = struct {
+	sync.Mutex
+	buffer map[string][]string
+	order  []string
+}{buffer: map[string][]string{}}
+
+// dial - connection to first reachable server, when it goes down grpc moves to next one of the list
+func dial(config *tls.Config) (*grpc.ClientConn, error) {
+	r := manual.NewBuilderWithScheme("dockerator")
+	addrs := []resolver.Address{}
+	for _, s := range cfg.Server {
+		// every server is verified by own name, not by the first one
+		host, _, err := net.SplitHostPort(s)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, resolver.Address{Addr: s, ServerName: host})
+	}
+	r.InitialState(resolver.State{Addresses: addrs})
+	return grpc.Dial(r.Scheme()+":///servers", grpc.WithResolvers(r), grpc.WithTransportCredentials(credentials.NewTLS(config)))
+}
+
+// reconnect - open persistent connection with current certificate, grpc reconnects it in background
+func reconnect() error {
+	conn, err := dial(clientTLS())
+	if err != nil {
+		return err
+	}
+	rpc.Lock()
+	old := rpc.conn
+	rpc.conn, rpc.client = conn, pb.NewDockeratorClient(conn)
+	rpc.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func rpcClient() pb.DockeratorClient {
+	rpc.Lock()
+	defer rpc.Unlock()
+	return rpc.client
+}
+
+// unauthenticated - server rejected node, it has to join again
+func unauthenticated(err error) bool {
+	return status.Code(err) == codes.Unauthenticated
+}
+
+// setOnline - track server availability, register again once server is back
+func setOnline(err error) {
+	// rejected call is still answered by server
+	if unauthenticated(err) {
+		err = nil
+	}
+	online.Lock()
+	wasUp := online.up
+	online.up = err == nil
+	online.Unlock()
+	switch {
+	case err != nil && wasUp:
+		log.Printf("Lost connection to server: %v", err)
+	case err == nil && !wasUp:
+		log.Println("Server is back, registering node again")
+		if err := nodeRegister(); err != nil {
+			log.Printf("Failed to register node: %v", err)
+		}
+	}
+}
+
+// backoff - exponential retry delay with jitter
+type backoff struct {
+	attempt int
+}
+
+func (b *backoff) next() time.Duration {
+	d := backoffBase << uint(b.attempt)
+	if d > cfg.MaxBackoff.Duration || d <= 0 {
+		d = cfg.MaxBackoff.Duration
+	} else {
+		b.attempt++
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
+
+// wait - sleep loop interval, or backoff delay after failed call
+func (b *backoff) wait(err error, interval time.Duration) {
+	if err == nil {
+		b.reset()
+		time.Sleep(interval)
+		return
+	}
+	d := b.next()
+	log.Printf("Server call failed, retrying in %v: %v", d.Round(time.Millisecond), err)
+	time.Sleep(d)
+}
+
+// bufferReport - keep report until server is back, older report of the same container is superseded
+func bufferReport(args []string) {
+	reports.Lock()
+	defer reports.Unlock()
+	name := args[1]
+	if _, ok := reports.buffer[name]; !ok {
+		if len(reports.order) >= reportBufferMax {
+			delete(reports.buffer, reports.order[0])
+			reports.order = reports.order[1:]
+		}
+		reports.order = append(reports.order, name)
+	}
+	reports.buffer[name] = args
+}
+
+// flushReports - send buffered reports, lock is not held while commands of server run
+func flushReports() error {
+	reports.Lock()
+	buffer, order := reports.buffer, reports.order
+	reports.buffer, reports.order = map[string][]string{}, nil
+	reports.Unlock()
+	for i, name := range order {
+		if err := sendMessage("check", buffer[name]...); err != nil {
+			// unsent reports go back unless newer ones came meanwhile
+			reports.Lock()
+			for _, n := range order[i:] {
+				if _, ok := reports.buffer[n]; !ok {
+					reports.buffer[n] = buffer[n]
+					reports.order = append(reports.order, n)
+				}
+			}
+			reports.Unlock()
+			return err
+		}
+	}
+	return nil
+}
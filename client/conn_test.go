@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	pb "dockerator/dockerator"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testServerTLS - self-signed certificate of 127.0.0.1 and pool trusting it
+func testServerTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+// testServer - gRPC server answering health checks on free port
+func testServer(t *testing.T, config *tls.Config) (*grpc.Server, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(config)))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return s, lis.Addr().String()
+}
+
+func healthy(conn *grpc.ClientConn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	return err
+}
+
+func TestDialFailover(t *testing.T) {
+	serverTLS, pool := testServerTLS(t)
+	// nothing listens on first address
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	down := lis.Addr().String()
+	lis.Close()
+	first, firstAddr := testServer(t, serverTLS)
+	_, secondAddr := testServer(t, serverTLS)
+
+	saved := cfg.Server
+	defer func() { cfg.Server = saved }()
+	cfg.Server = servers{down, firstAddr, secondAddr}
+	conn, err := dial(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := healthy(conn); err != nil {
+		t.Fatalf("call with first server down: %v", err)
+	}
+	// call racing with closed connection fails, agent retries it with backoff
+	first.Stop()
+	for i := 0; ; i++ {
+		err := healthy(conn)
+		if err == nil {
+			break
+		}
+		if i == 3 {
+			t.Fatalf("call after server went down: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	saved := cfg.MaxBackoff
+	defer func() { cfg.MaxBackoff = saved }()
+	cfg.MaxBackoff = duration{8 * time.Second}
+	b := backoff{}
+	// delay doubles up to max, jitter keeps it within upper half
+	for _, max := range []time.Duration{1, 2, 4, 8, 8, 8} {
+		max *= time.Second
+		if d := b.next(); d < max/2 || d > max {
+			t.Fatalf("delay %v out of [%v, %v]", d, max/2, max)
+		}
+	}
+	b.reset()
+	if d := b.next(); d > time.Second {
+		t.Fatalf("delay after reset = %v", d)
+	}
+	// attempt does not overflow shift while server stays down
+	b.attempt = 70
+	if d := b.next(); d < 4*time.Second || d > 8*time.Second {
+		t.Fatalf("delay after many attempts = %v", d)
+	}
+}
+
+// fakeServer - CheckWorker of server failing for one container, agent reports meanwhile while it fails
+type fakeServer struct {
+	pb.DockeratorClient
+	fail      string
+	meanwhile []string
+	sent      []string
+}
+
+func (f *fakeServer) CheckWorker(ctx context.Context, in *pb.Request, opts ...grpc.CallOption) (*pb.Response, error) {
+	if in.Service == f.fail {
+		if f.meanwhile != nil {
+			bufferReport(f.meanwhile)
+		}
+		return nil, errors.New("unavailable")
+	}
+	f.sent = append(f.sent, in.Service+" "+in.State)
+	return &pb.Response{Status: true}, nil
+}
+
+// testReports - empty report buffer and fake server, restored after test
+func testReports(t *testing.T, server *fakeServer) {
+	reports.Lock()
+	reports.buffer, reports.order = map[string][]string{}, nil
+	reports.Unlock()
+	rpc.Lock()
+	rpc.client = server
+	rpc.Unlock()
+	t.Cleanup(func() {
+		rpc.Lock()
+		rpc.client = nil
+		rpc.Unlock()
+		online.Lock()
+		online.up = true
+		online.Unlock()
+	})
+}
+
+func TestReportBuffer(t *testing.T) {
+	server := &fakeServer{fail: "web-2", meanwhile: []string{"n1", "web-3", "exited"}}
+	testReports(t, server)
+	bufferReport([]string{"n1", "web-1", "running"})
+	bufferReport([]string{"n1", "web-2", "running"})
+	bufferReport([]string{"n1", "web-3", "running"})
+	// newer report supersedes older one and keeps its place
+	bufferReport([]string{"n1", "web-1", "exited"})
+
+	if err := flushReports(); err == nil {
+		t.Fatal("flush with failing report succeeded")
+	}
+	if fmt.Sprint(server.sent) != "[web-1 exited]" {
+		t.Fatalf("sent = %v", server.sent)
+	}
+	// unsent reports go back, report of the same container made during flush wins
+	if fmt.Sprint(reports.order) != "[web-3 web-2]" || reports.buffer["web-3"][2] != "exited" {
+		t.Fatalf("buffer = %v %v", reports.order, reports.buffer)
+	}
+
+	server.fail = ""
+	online.Lock()
+	online.up = true
+	online.Unlock()
+	if err := flushReports(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(server.sent) != "[web-1 exited web-3 exited web-2 running]" || len(reports.order) != 0 {
+		t.Fatalf("sent = %v, left %v", server.sent, reports.order)
+	}
+}
+
+func TestReportBufferLimit(t *testing.T) {
+	testReports(t, &fakeServer{})
+	for i := 0; i < reportBufferMax+10; i++ {
+		bufferReport([]string{"n1", fmt.Sprintf("web-%v", i), "running"})
+	}
+	if len(reports.order) != reportBufferMax || len(reports.buffer) != reportBufferMax {
+		t.Fatalf("buffer holds %v reports", len(reports.order))
+	}
+	// oldest reports are dropped first
+	if reports.order[0] != "web-10" {
+		t.Fatalf("oldest kept report = %v", reports.order[0])
+	}
+}
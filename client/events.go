@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"dockerator/docker"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// resync - request full containers resync, e.g. after missed events
+var resync = make(chan bool, 1)
+
+// reportedEvents - container transitions reported to server
+var reportedEvents = map[string]bool{
+	"start":         true,
+	"die":           true,
+	"oom":           true,
+	"health_status": true,
+}
+
+func requestResync() {
+	select {
+	case resync <- true:
+	default:
+	}
+}
+
+// eventsLoop - report container state transitions from Docker events stream
+func eventsLoop() {
+	b := &backoff{}
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		msgs, errs := docker.Events(ctx)
+		// events could be missed while stream was down
+		requestResync()
+		err := watchEvents(msgs, errs, b)
+		cancel()
+		d := b.next()
+		log.Printf("Docker events stream failed, reconnecting in %v: %v", d.Round(time.Millisecond), err)
+		time.Sleep(d)
+	}
+}
+
+func watchEvents(msgs <-chan events.Message, errs <-chan error, b *backoff) error {
+	for {
+		select {
+		case msg := <-msgs:
+			b.reset()
+			reportEvent(msg)
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+func reportEvent(msg events.Message) {
+	// health events come as "health_status: healthy"
+	event := strings.SplitN(msg.Action, ":", 2)[0]
+	if !reportedEvents[event] {
+		return
+	}
+	managed := msg.Actor.Attributes[docker.ManagedLabel] == "true"
+	if !managed && !cfg.ReportUnmanaged {
+		return
+	}
+	name := msg.Actor.Attributes["name"]
+	state, exitCode := docker.ContainerState(msg.Actor.ID)
+	if event == "die" {
+		// container could be already restarted, report exit of this run
+		state, exitCode = "exited", 0
+		fmt.Sscan(msg.Actor.Attributes["exitCode"], &exitCode)
+	}
+	log.Printf("%v - %v - %v (exit code %v)", name, msg.Action, state, exitCode)
+	args := []string{node, name, state, fmt.Sprintf("%v", managed), msg.Action, fmt.Sprintf("%v", exitCode)}
+	if err := sendMessage("check", args...); err != nil {
+		bufferReport(args)
+	}
+}
@@ -4,88 +4,147 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
+	"math/rand"
 	"time"
 
 	"dockerator/docker"
 	pb "dockerator/dockerator"
-
-	"google.golang.org/grpc"
-)
-
-const (
-	address = "172.17.0.1:50051"
+	"dockerator/metrics"
 )
 
-var node = docker.GetNodeIP("eth0")
+var node string
 
 func main() {
-	nodeRegister()
+	loadConfig()
+	node = cfg.NodeName
+	log.Printf("Agent %v (%v) connecting to %v", node, cfg.NodeIP, cfg.Server)
+	rand.Seed(time.Now().UnixNano())
+	b := &backoff{}
+	for err := join(); err != nil; err = join() {
+		b.wait(err, 0)
+	}
+	for err := nodeRegister(); err != nil; err = nodeRegister() {
+		b.wait(err, 0)
+	}
+	go certRotationLoop()
 	go checkContainersLoop()
+	go eventsLoop()
 	go checkForTaskLoop()
+	go heartbeatLoop()
+	go statsLoop()
+	if cfg.MetricsAddr != "" {
+		go metrics.Start(cfg.MetricsAddr)
+	}
 	for {
 		time.Sleep(5 * time.Second)
 	}
 }
 
-func sendMessage(msgType string, args ...string) {
-	// Set up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
-	if err != nil {
-		log.Fatalf("did not connect: %v", err)
-	}
-	defer conn.Close()
-	c := pb.NewDockeratorClient(conn)
+func sendMessage(msgType string, args ...string) (err error) {
+	c := rpcClient()
 
 	// Contact the server and print out its response.
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
+	defer func() {
+		setOnline(err)
+	}()
 	switch msgType {
 	case "check":
 		node, service, state := args[0], args[1], args[2]
-		r, err := c.CheckWorker(ctx, &pb.Request{Node: node, Service: service, State: state})
+		request := &pb.Request{Node: node, Service: service, State: state}
+		if len(args) > 3 {
+			request.Managed = args[3] == "true"
+		}
+		if len(args) > 5 {
+			request.Event = args[4]
+			fmt.Sscan(args[5], &request.ExitCode)
+		}
+		r, err := c.CheckWorker(ctx, request)
 		if err != nil {
-			log.Fatalf("could not check: %v", err)
+			return err
 		}
 		log.Printf("CheckRespond: %v %v %v", r.Command, r.Params, r.Status)
 		if r.Status != true {
 			log.Printf("Fix service: %v %v\n", r.Command, r.Params)
-			docker.Container(r.Command, r.Params)
+			docker.Container(r.Command, r.Params, taskOptions(r.Secrets, r.Configs, r.Registry, r.PullPolicy))
 		}
 	case "task":
 		node := args[0]
 		r, err := c.CheckForTask(ctx, &pb.TaskRequest{Node: node})
 		if err != nil {
-			log.Fatalf("could not check: %v", err)
+			return err
 		}
 		log.Printf("TaskRespond: %v %v ", r.Job, r.Params)
 		if r.Job != "nojob" {
 			log.Printf("Doing task: %v %v\n", r.Job, r.Params)
-			docker.Container(r.Job, r.Params)
+			docker.Container(r.Job, r.Params, taskOptions(r.Secrets, r.Configs, r.Registry, r.PullPolicy))
+		}
+	case "heartbeat":
+		node := args[0]
+		r, err := c.Heartbeat(ctx, &pb.HeartbeatRequest{Node: node, Ip: cfg.NodeIP})
+		if unauthenticated(err) {
+			log.Println("Node is rejected by server, registering again")
+			return nodeRegister()
+		}
+		if err != nil {
+			return err
+		}
+		if r.Status != true {
+			log.Println("Node is unknown to server, registering again")
+			return nodeRegister()
 		}
 	default:
 		log.Println("wrong msgType.")
 	}
+	return nil
 }
 
+// checkContainersLoop - periodic full resync of node status, transitions are reported by eventsLoop
 func checkContainersLoop() {
+	b := &backoff{}
 	for {
-		containers := docker.PS("all")
-		for _, container := range containers {
-			fmt.Printf("%v - %v - %v\n", node, strings.TrimLeft(container.Names[0], "/"), container.State)
-			sendMessage("check", node, strings.TrimLeft(container.Names[0], "/"), container.State)
+		// buffered transitions go first, full report then supersedes them
+		err := flushReports()
+		if err == nil {
+			err = reportNodeStatus()
+		}
+		if err != nil {
+			b.wait(err, 0)
+			continue
+		}
+		b.reset()
+		select {
+		case <-time.After(cfg.ResyncInterval.Duration):
+		case <-resync:
 		}
-		time.Sleep(5 * time.Second)
 	}
 }
 
-func nodeRegister() {
-	sendMessage("check", node, "nodereg", "running")
+func nodeRegister() error {
+	err := sendMessage("check", node, "nodereg", "running")
+	if unauthenticated(err) {
+		// certificate is not accepted anymore, join with token again
+		log.Printf("Registration rejected, joining again: %v", err)
+		if err = join(); err == nil {
+			err = sendMessage("check", node, "nodereg", "running")
+		}
+	}
+	return err
 }
 
 func checkForTaskLoop() {
+	b := &backoff{}
 	for {
-		sendMessage("task", node)
-		time.Sleep(5 * time.Second)
+		err := sendMessage("task", node)
+		b.wait(err, cfg.PollInterval.Duration)
+	}
+}
+
+func heartbeatLoop() {
+	b := &backoff{}
+	for {
+		err := sendMessage("heartbeat", node)
+		b.wait(err, cfg.HeartbeatInterval.Duration)
 	}
 }
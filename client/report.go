@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"dockerator/docker"
+	pb "dockerator/dockerator"
+)
+
+// nodeInventory - full status of node containers and node stats for one report
+func nodeInventory() *pb.NodeStatusReport {
+	report := &pb.NodeStatusReport{Node: node, Ip: cfg.NodeIP, Stats: &pb.NodeStats{}, Timestamp: time.Now().Unix()}
+	for _, c := range docker.PS("all") {
+		managed := docker.IsManaged(c)
+		if !managed && !cfg.ReportUnmanaged {
+			continue
+		}
+		status := &pb.ContainerStatus{
+			Id:      c.ID,
+			Name:    strings.TrimLeft(c.Names[0], "/"),
+			Image:   c.Image,
+			State:   c.State,
+			Managed: managed,
+			Service: c.Labels[docker.ServiceLabel],
+			Task:    c.Labels[docker.TaskLabel],
+		}
+		if info, err := docker.Inspect(c.ID); err == nil && info.State != nil {
+			status.ExitCode = int32(info.State.ExitCode)
+			if info.State.Health != nil {
+				status.Health = info.State.Health.Status
+			}
+			if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil && !started.IsZero() {
+				status.StartedAt = started.Unix()
+			}
+		}
+		addContainerUsage(status)
+		report.Containers = append(report.Containers, status)
+	}
+	if info, err := docker.Info(); err == nil {
+		report.Stats = &pb.NodeStats{
+			Cpus:       int32(info.NCPU),
+			Memory:     info.MemTotal,
+			Containers: int32(info.Containers),
+			Running:    int32(info.ContainersRunning),
+		}
+		addUsage(report.Stats)
+	} else {
+		log.Printf("Failed to get node stats: %v", err)
+	}
+	return report
+}
+
+// reportNodeStatus - send full inventory in one call and apply returned commands
+func reportNodeStatus() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer func() {
+		setOnline(err)
+	}()
+	report := nodeInventory()
+	r, err := rpcClient().ReportNodeStatus(ctx, report)
+	if err != nil && !unauthenticated(err) {
+		return err
+	}
+	if err != nil || !r.Registered {
+		log.Println("Node is unknown to server, registering again")
+		if err = nodeRegister(); err == nil {
+			requestResync()
+		}
+		return err
+	}
+	log.Printf("Reported %v containers, %v commands", len(report.Containers), len(r.Commands))
+	for _, c := range r.Commands {
+		log.Printf("Fix service: %v %v\n", c.Command, c.Params)
+		docker.Container(c.Command, c.Params, taskOptions(c.Secrets, c.Configs, c.Registry, c.PullPolicy))
+	}
+	return nil
+}
+
+// taskOptions - payload of command for docker, secret values and passwords are never logged
+func taskOptions(secrets []*pb.Secret, configs []*pb.Config, registry *pb.Registry, pullPolicy string) (opts docker.Options) {
+	opts.PullPolicy, opts.Status = pullPolicy, reportTaskStatus
+	for _, s := range secrets {
+		opts.Secrets = append(opts.Secrets, docker.Secret{Name: s.Name, Value: s.Value, Env: s.Env, File: s.File})
+	}
+	for _, c := range configs {
+		opts.Configs = append(opts.Configs, docker.Config{Name: c.Name, Data: c.Data, Path: c.Path, Version: c.Version})
+	}
+	if registry != nil {
+		opts.Registry = &docker.Registry{Server: registry.Server, Username: registry.Username, Password: registry.Password}
+	}
+	return
+}
+
+// reportTaskStatus - send phase of container task to server, a deploy stuck pulling is visible in /state
+func reportTaskStatus(container, phase, detail, digest string) {
+	log.Printf("Task of %v: %v %v", container, phase, detail)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	status := &pb.TaskStatus{Node: node, Container: container, Phase: phase, Detail: detail, Digest: digest, Timestamp: time.Now().Unix()}
+	if _, err := rpcClient().ReportTaskStatus(ctx, status); err != nil {
+		log.Printf("Failed to report task status of %v: %v", container, err)
+	}
+}
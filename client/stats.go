@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"dockerator/docker"
+	pb "dockerator/dockerator"
+	"dockerator/metrics"
+
+	"github.com/docker/docker/api/types"
+)
+
+// usage - stats of managed containers and their totals from last stats round, sent with node report
+var usage = struct {
+	sync.Mutex
+	stats      docker.Stats
+	containers map[string]docker.Stats
+}{}
+
+// containerGauges - series of each managed container, dropped when container stops
+var containerGauges = map[string]string{
+	"dockerator_container_cpu_percent":        "CPU usage of container, percent of one core.",
+	"dockerator_container_memory_bytes":       "Memory used by container without page cache.",
+	"dockerator_container_memory_limit_bytes": "Memory limit of container.",
+}
+
+// containerCounters - totals Docker counts since container start, dropped with gauges
+var containerCounters = map[string]string{
+	"dockerator_container_network_rx_bytes_total":  "Bytes received by container since start.",
+	"dockerator_container_network_tx_bytes_total":  "Bytes sent by container since start.",
+	"dockerator_container_block_read_bytes_total":  "Bytes read from block devices since start.",
+	"dockerator_container_block_write_bytes_total": "Bytes written to block devices since start.",
+}
+
+// statsWorkers - containers sampled at once, each stats read takes about a second
+const statsWorkers = 8
+
+type containerSample struct {
+	labels metrics.Labels
+	stats  docker.Stats
+}
+
+// statsLoop - sample managed containers, serve them on agent metrics endpoint
+func statsLoop() {
+	for name, help := range containerGauges {
+		metrics.Help(name, help)
+	}
+	for name, help := range containerCounters {
+		metrics.Help(name, help)
+	}
+	metrics.Help("dockerator_container_stats_errors_total", "Failed reads of container stats.")
+	metrics.Help("dockerator_container_stats_duration_seconds", "Duration of last stats round of all containers.")
+	seen := map[string]metrics.Labels{}
+	for {
+		start := time.Now()
+		current := map[string]metrics.Labels{}
+		total := docker.Stats{}
+		containers := map[string]docker.Stats{}
+		for name, sample := range sampleContainers() {
+			s, labels := sample.stats, sample.labels
+			current[name] = labels
+			containers[name] = s
+			metrics.SetGauge("dockerator_container_cpu_percent", labels, s.CPUPercent)
+			metrics.SetGauge("dockerator_container_memory_bytes", labels, float64(s.Memory))
+			metrics.SetGauge("dockerator_container_memory_limit_bytes", labels, float64(s.MemoryLimit))
+			metrics.SetCounter("dockerator_container_network_rx_bytes_total", labels, float64(s.NetRx))
+			metrics.SetCounter("dockerator_container_network_tx_bytes_total", labels, float64(s.NetTx))
+			metrics.SetCounter("dockerator_container_block_read_bytes_total", labels, float64(s.BlockRead))
+			metrics.SetCounter("dockerator_container_block_write_bytes_total", labels, float64(s.BlockWrite))
+			total.CPUPercent += s.CPUPercent
+			total.Memory += s.Memory
+			total.NetRx += s.NetRx
+			total.NetTx += s.NetTx
+			total.BlockRead += s.BlockRead
+			total.BlockWrite += s.BlockWrite
+		}
+		// series of stopped and removed containers disappear
+		for name, labels := range seen {
+			if _, ok := current[name]; !ok {
+				for gauge := range containerGauges {
+					metrics.Delete(gauge, labels)
+				}
+				for counter := range containerCounters {
+					metrics.Delete(counter, labels)
+				}
+			}
+		}
+		seen = current
+		metrics.SetGauge("dockerator_container_stats_duration_seconds", metrics.Labels{"node": node}, time.Since(start).Seconds())
+		usage.Lock()
+		usage.stats, usage.containers = total, containers
+		usage.Unlock()
+		time.Sleep(cfg.StatsInterval.Duration)
+	}
+}
+
+// sampleContainers - stats of running managed containers read by statsWorkers at once, round takes
+// as long as the slowest containers instead of all of them
+func sampleContainers() map[string]containerSample {
+	samples := map[string]containerSample{}
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	workers := make(chan struct{}, statsWorkers)
+	for _, c := range docker.PS("up") {
+		if !docker.IsManaged(c) {
+			continue
+		}
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(c types.Container) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			name := strings.TrimLeft(c.Names[0], "/")
+			s, err := docker.ContainerStats(c.ID)
+			if err != nil {
+				log.Printf("Failed to get stats of %v: %v", name, err)
+				metrics.AddCounter("dockerator_container_stats_errors_total", metrics.Labels{"node": node}, 1)
+				return
+			}
+			labels := metrics.Labels{"node": node, "container": name,
+				"service": c.Labels[docker.ServiceLabel], "namespace": c.Labels[docker.NamespaceLabel]}
+			lock.Lock()
+			samples[name] = containerSample{labels, s}
+			lock.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return samples
+}
+
+// addUsage - copy totals of last stats round to node stats
+func addUsage(stats *pb.NodeStats) {
+	usage.Lock()
+	defer usage.Unlock()
+	stats.CpuPercent = usage.stats.CPUPercent
+	stats.MemoryUsage = usage.stats.Memory
+	stats.NetRx = usage.stats.NetRx
+	stats.NetTx = usage.stats.NetTx
+	stats.BlockRead = usage.stats.BlockRead
+	stats.BlockWrite = usage.stats.BlockWrite
+}
+
+// addContainerUsage - copy last stats of container to its status, autoscaling on server uses them
+func addContainerUsage(status *pb.ContainerStatus) {
+	usage.Lock()
+	defer usage.Unlock()
+	if s, ok := usage.containers[status.Name]; ok {
+		status.CpuPercent = s.CPUPercent
+		status.MemoryUsage = s.Memory
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+
+	pb "dockerator/dockerator"
+)
+
+// caPool - cluster CA agent trusts servers by
+var caPool *x509.CertPool
+
+// identity - node certificate issued by server CA
+var identity = struct {
+	sync.Mutex
+	cert *tls.Certificate
+}{}
+
+func loadCA() {
+	data, err := ioutil.ReadFile(cfg.CACert)
+	if err != nil {
+		log.Fatalf("failed to read CA certificate: %v", err)
+	}
+	caPool = x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(data) {
+		log.Fatalf("no certificates in %v", cfg.CACert)
+	}
+}
+
+func clientTLS() *tls.Config {
+	return &tls.Config{
+		RootCAs: caPool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			identity.Lock()
+			defer identity.Unlock()
+			if identity.cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return identity.cert, nil
+		},
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// newCSR - fresh key and certificate request of node
+func newCSR() (*ecdsa.PrivateKey, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: node}}, key)
+	return key, csr, err
+}
+
+// setCertificate - use issued certificate for new connection to server
+func setCertificate(key *ecdsa.PrivateKey, certPEM []byte) error {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return errors.New("wrong certificate PEM")
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+	identity.Lock()
+	identity.cert = &tls.Certificate{Certificate: [][]byte{block.Bytes}, PrivateKey: key, Leaf: leaf}
+	identity.Unlock()
+	log.Printf("Node certificate valid until %v", leaf.NotAfter.Format(time.RFC3339))
+	return reconnect()
+}
+
+// join - get node certificate with join token
+func join() error {
+	key, csr, err := newCSR()
+	if err != nil {
+		return err
+	}
+	// node has no certificate yet, server is verified by CA only
+	conn, err := dial(&tls.Config{RootCAs: caPool, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := pb.NewDockeratorClient(conn).Join(ctx, &pb.JoinRequest{Node: node, Token: cfg.JoinToken, Csr: csr})
+	if err != nil {
+		return err
+	}
+	return setCertificate(key, r.Certificate)
+}
+
+// renew - replace certificate before it expires, current one authenticates the call
+func renew() error {
+	key, csr, err := newCSR()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := rpcClient().RenewCertificate(ctx, &pb.CertificateRequest{Node: node, Csr: csr})
+	if err != nil {
+		return err
+	}
+	return setCertificate(key, r.Certificate)
+}
+
+// expiring - less than third of certificate lifetime left
+func expiring() bool {
+	identity.Lock()
+	defer identity.Unlock()
+	if identity.cert == nil {
+		return true
+	}
+	leaf := identity.cert.Leaf
+	return time.Until(leaf.NotAfter) < leaf.NotAfter.Sub(leaf.NotBefore)/3
+}
+
+// certRotationLoop - renew node certificate, join again if server doesn't accept it
+func certRotationLoop() {
+	b := &backoff{}
+	for {
+		var err error
+		if expiring() {
+			if err = renew(); unauthenticated(err) {
+				err = join()
+			}
+			if err == nil {
+				log.Println("Rotated node certificate")
+			}
+		}
+		b.wait(err, time.Minute)
+	}
+}
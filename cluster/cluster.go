@@ -0,0 +1,305 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	kv "dockerator/kvstore"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+)
+
+const applyTimeout = 5 * time.Second
+
+// Config - settings of local control plane instance
+type Config struct {
+	ID        string
+	RaftAddr  string
+	RaftDir   string
+	HTTPAddr  string
+	GRPCAddr  string
+	Bootstrap bool
+}
+
+// Member - addresses of control plane instance
+type Member struct {
+	ID   string `json:"id"`
+	HTTP string `json:"http"`
+	GRPC string `json:"grpc"`
+}
+
+// Cluster - control plane membership used by server
+type Cluster interface {
+	IsLeader() bool
+	Leader() (Member, error)
+	Join(m Member, addr string) error
+	Status() map[string]interface{}
+	Shutdown() error
+}
+
+// Node - local raft member replicating kvstore
+type Node struct {
+	cfg  Config
+	db   kv.Store
+	raft *raft.Raft
+
+	mu sync.Mutex
+	// ready - log of previous terms is applied to db since local election
+	ready bool
+}
+
+// memberKey - addresses of member, leader is looked up by its raft id
+func memberKey(id string) string {
+	return "ClusterMember-" + id
+}
+
+type command struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Start - run raft member on top of local db
+func Start(cfg Config, db kv.Store) (n *Node, err error) {
+	n = &Node{cfg: cfg, db: db}
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(cfg.ID)
+
+	if err = os.MkdirAll(cfg.RaftDir, 0700); err != nil {
+		return nil, err
+	}
+	addr, err := net.ResolveTCPAddr("tcp", cfg.RaftAddr)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := raft.NewTCPTransport(cfg.RaftAddr, addr, 3, 10*time.Second, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := raft.NewFileSnapshotStore(cfg.RaftDir, 2, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	store, err := raftboltdb.NewBoltStore(filepath.Join(cfg.RaftDir, "raft.db"))
+	if err != nil {
+		return nil, err
+	}
+	n.raft, err = raft.NewRaft(config, &fsm{db}, store, store, snapshots, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Bootstrap {
+		configuration := raft.Configuration{
+			Servers: []raft.Server{{ID: config.LocalID, Address: transport.LocalAddr()}},
+		}
+		if err := n.raft.BootstrapCluster(configuration).Error(); err != nil && err != raft.ErrCantBootstrap {
+			return nil, err
+		}
+	}
+	go n.leaderLoop()
+	return n, nil
+}
+
+// IsLeader - check if local instance leads the cluster and its db is up to date
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	ready := n.ready
+	n.mu.Unlock()
+	return ready && n.raft.State() == raft.Leader
+}
+
+func (n *Node) setReady(ready bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ready = ready
+}
+
+// Leader - return addresses of current leader
+func (n *Node) Leader() (leader Member, err error) {
+	if n.IsLeader() {
+		return n.self(), nil
+	}
+	_, id := n.raft.LeaderWithID()
+	switch string(id) {
+	case "":
+		return leader, fmt.Errorf("no leader elected")
+	case n.cfg.ID:
+		return leader, fmt.Errorf("leader is not ready")
+	}
+	v, err := n.db.Get([]byte(memberKey(string(id))))
+	if err != nil {
+		return leader, fmt.Errorf("unknown addresses of leader %v: %v", id, err)
+	}
+	err = json.Unmarshal(v, &leader)
+	return
+}
+
+// Apply - replicate write operation, used as kvstore replicator
+func (n *Node) Apply(op, key, value string) error {
+	cmd, err := json.Marshal(command{op, key, value})
+	if err != nil {
+		return err
+	}
+	f := n.raft.Apply(cmd, applyTimeout)
+	if err := f.Error(); err != nil {
+		return err
+	}
+	if err, ok := f.Response().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Join - add new voter to the cluster, its addresses are stored first so it can be found once it leads
+func (n *Node) Join(m Member, addr string) error {
+	log.Printf("Node %v at %v joining cluster", m.ID, addr)
+	if err := n.putMember(m); err != nil {
+		return err
+	}
+	return n.raft.AddVoter(raft.ServerID(m.ID), raft.ServerAddress(addr), 0, 0).Error()
+}
+
+func (n *Node) putMember(m Member) error {
+	v, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return n.Apply("put", memberKey(m.ID), string(v))
+}
+
+// Status - state of local member and known peers
+func (n *Node) Status() (status map[string]interface{}) {
+	leader, _ := n.raft.LeaderWithID()
+	status = map[string]interface{}{
+		"id":     n.cfg.ID,
+		"state":  n.raft.State().String(),
+		"leader": string(leader),
+	}
+	f := n.raft.GetConfiguration()
+	if err := f.Error(); err == nil {
+		peers := []string{}
+		for _, s := range f.Configuration().Servers {
+			peers = append(peers, fmt.Sprintf("%v=%v", s.ID, s.Address))
+		}
+		status["peers"] = peers
+	}
+	return
+}
+
+// Shutdown - leave raft gracefully
+func (n *Node) Shutdown() error {
+	return n.raft.Shutdown().Error()
+}
+
+func (n *Node) self() Member {
+	return Member{n.cfg.ID, n.cfg.HTTPAddr, n.cfg.GRPCAddr}
+}
+
+// leaderLoop - new leader acts on db only after barrier applied log of previous terms,
+// its addresses are published so followers can redirect to it
+func (n *Node) leaderLoop() {
+	for isLeader := range n.raft.LeaderCh() {
+		n.setReady(false)
+		if !isLeader {
+			log.Println("Lost cluster leadership")
+			continue
+		}
+		log.Println("Became cluster leader")
+		if !n.barrier() {
+			continue
+		}
+		if err := n.putMember(n.self()); err != nil {
+			log.Printf("Failed to publish leader address: %v", err)
+		}
+		n.setReady(true)
+	}
+}
+
+// barrier - wait until log is applied, false if leadership was lost meanwhile
+func (n *Node) barrier() bool {
+	for n.raft.State() == raft.Leader {
+		err := n.raft.Barrier(applyTimeout).Error()
+		if err == nil {
+			return true
+		}
+		log.Printf("Failed to apply log of previous leaders: %v", err)
+		time.Sleep(time.Second)
+	}
+	return false
+}
+
+type fsm struct {
+	db kv.Store
+}
+
+func (f *fsm) Apply(l *raft.Log) interface{} {
+	cmd := command{}
+	if err := json.Unmarshal(l.Data, &cmd); err != nil {
+		return err
+	}
+	return kv.ApplyLocal(f.db, cmd.Op, cmd.Key, cmd.Value)
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	// values are read after scan, nested Get under scan lock can deadlock with waiting writer
+	keys := kv.KeysList(f.db, "")
+	state := map[string]string{}
+	for _, k := range keys {
+		v, err := f.db.Get([]byte(k))
+		if err == kv.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		state[k] = string(v)
+	}
+	return &snapshot{state}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	state := map[string]string{}
+	if err := json.NewDecoder(rc).Decode(&state); err != nil {
+		return err
+	}
+	keys := [][]byte{}
+	f.db.Scan(nil, func(key []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	for _, k := range keys {
+		if err := f.db.Delete(k); err != nil {
+			return err
+		}
+	}
+	for k, v := range state {
+		if err := f.db.Put([]byte(k), []byte(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type snapshot struct {
+	state map[string]string
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s.state); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+const electionPrefix = "/dockerator/election"
+
+// EtcdNode - control plane member sharing state through etcd, leader elected by etcd lease
+type EtcdNode struct {
+	cfg    Config
+	cli    *clientv3.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	isLeader bool
+	election *concurrency.Election
+}
+
+// StartEtcd - campaign for leadership among servers sharing etcd
+func StartEtcd(cfg Config, cli *clientv3.Client) (n *EtcdNode, err error) {
+	n = &EtcdNode{cfg: cfg, cli: cli}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	go n.campaignLoop()
+	return n, nil
+}
+
+func (n *EtcdNode) setState(isLeader bool, election *concurrency.Election) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.isLeader, n.election = isLeader, election
+}
+
+func (n *EtcdNode) campaignLoop() {
+	self, _ := json.Marshal(Member{n.cfg.ID, n.cfg.HTTPAddr, n.cfg.GRPCAddr})
+	for n.ctx.Err() == nil {
+		session, err := concurrency.NewSession(n.cli, concurrency.WithTTL(10))
+		if err != nil {
+			log.Printf("Failed to create etcd session: %v", err)
+			time.Sleep(3 * time.Second)
+			continue
+		}
+		election := concurrency.NewElection(session, electionPrefix)
+		n.setState(false, election)
+		if err := election.Campaign(n.ctx, string(self)); err != nil {
+			log.Printf("Campaign failed: %v", err)
+			session.Close()
+			continue
+		}
+		log.Println("Became cluster leader")
+		n.setState(true, election)
+		select {
+		case <-session.Done():
+			log.Println("Lost cluster leadership")
+		case <-n.ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			election.Resign(ctx)
+			cancel()
+		}
+		n.setState(false, election)
+		session.Close()
+	}
+}
+
+// IsLeader - check if local instance leads the cluster
+func (n *EtcdNode) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.isLeader
+}
+
+// Leader - return addresses of current leader
+func (n *EtcdNode) Leader() (leader Member, err error) {
+	n.mu.Lock()
+	election := n.election
+	n.mu.Unlock()
+	if election == nil {
+		return leader, fmt.Errorf("no leader elected")
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, time.Second)
+	defer cancel()
+	resp, err := election.Leader(ctx)
+	if err != nil {
+		return leader, err
+	}
+	err = json.Unmarshal(resp.Kvs[0].Value, &leader)
+	return
+}
+
+// Join - etcd members share state directly, nothing to join
+func (n *EtcdNode) Join(m Member, addr string) error {
+	return fmt.Errorf("servers with etcd store join by using the same etcd endpoints")
+}
+
+// Status - state of local member and current leader
+func (n *EtcdNode) Status() (status map[string]interface{}) {
+	state := "Follower"
+	if n.IsLeader() {
+		state = "Leader"
+	}
+	status = map[string]interface{}{
+		"id":    n.cfg.ID,
+		"state": state,
+		"store": "etcd",
+	}
+	if leader, err := n.Leader(); err == nil {
+		status["leader"] = leader.ID
+	}
+	return
+}
+
+// Shutdown - resign leadership
+func (n *EtcdNode) Shutdown() error {
+	n.cancel()
+	return nil
+}
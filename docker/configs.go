@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Config - config blob mounted into container at path
+type Config struct {
+	Name    string
+	Data    []byte
+	Path    string
+	Version int64
+}
+
+// configsDir - host directory keeping config files of containers
+var configsDir = "/var/lib/dockerator/configs"
+
+// SetConfigsDir - keep config files of containers in dir
+func SetConfigsDir(dir string) {
+	configsDir = dir
+}
+
+// mountConfigs - write config files of container and mount each read only at its path, container
+// must not start without them
+func mountConfigs(hostConfig *container.HostConfig, contName string, configs []Config) error {
+	if len(configs) == 0 {
+		return nil
+	}
+	dir := filepath.Join(configsDir, contName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create configs dir: %v", err)
+	}
+	binds := []string{}
+	for _, c := range configs {
+		file := filepath.Join(dir, filepath.Base(c.Name))
+		if err := ioutil.WriteFile(file, c.Data, 0444); err != nil {
+			removeConfigs(contName)
+			return fmt.Errorf("failed to write config %v: %v", c.Name, err)
+		}
+		binds = append(binds, file+":"+c.Path+":ro")
+	}
+	hostConfig.Binds = append(hostConfig.Binds, binds...)
+	return nil
+}
+
+// removeConfigs - drop config files of removed container
+func removeConfigs(contName string) {
+	if err := os.RemoveAll(filepath.Join(configsDir, contName)); err != nil {
+		log.Printf("Failed to remove configs of %v: %v", contName, err)
+	}
+}
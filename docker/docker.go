@@ -2,17 +2,17 @@ package docker
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/rs/xid"
 	"golang.org/x/net/context"
@@ -20,57 +20,116 @@ import (
 
 var ctx = context.Background()
 
+// Labels of containers created by dockerator
+const (
+	ManagedLabel   = "dockerator.managed"
+	ServiceLabel   = "dockerator.service"
+	TaskLabel      = "dockerator.task"
+	NamespaceLabel = "dockerator.namespace"
+)
+
+// managedLabels - ownership labels from optional trailing "service task namespace" args
+func managedLabels(args ...string) map[string]string {
+	labels := map[string]string{ManagedLabel: "true"}
+	for i, label := range []string{ServiceLabel, TaskLabel, NamespaceLabel} {
+		if len(args) > i {
+			labels[label] = args[i]
+		}
+	}
+	return labels
+}
+
+// taskHostConfig - network of namespace and resource limits from optional trailing "service task namespace cpus memory" args
+func taskHostConfig(args ...string) *container.HostConfig {
+	hostConfig := &container.HostConfig{}
+	if len(args) > 2 {
+		network, err := ensureNetwork(args[2])
+		if err != nil {
+			log.Println(err)
+		} else {
+			hostConfig.NetworkMode = container.NetworkMode(network)
+		}
+	}
+	if len(args) > 4 {
+		var cpus float64
+		var memory int64
+		fmt.Sscan(args[3], &cpus)
+		fmt.Sscan(args[4], &memory)
+		hostConfig.NanoCPUs = int64(cpus * 1e9)
+		// memory is in MB
+		hostConfig.Memory = memory << 20
+	}
+	return hostConfig
+}
+
+// ensureNetwork - bridge network isolating containers of namespace on node
+func ensureNetwork(namespace string) (string, error) {
+	cli := dockerCli()
+	name := "dockerator-" + namespace
+	if _, err := cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{}); err == nil {
+		return name, nil
+	}
+	_, err := cli.NetworkCreate(ctx, name, types.NetworkCreate{
+		CheckDuplicate: true,
+		Labels:         map[string]string{ManagedLabel: "true", NamespaceLabel: namespace},
+	})
+	return name, err
+}
+
+// dockerHost - Docker endpoint, DOCKER_HOST env is used when empty
+var dockerHost string
+
+// SetHost - use Docker endpoint instead of DOCKER_HOST env
+func SetHost(host string) {
+	dockerHost = host
+}
+
 func dockerCli() (cli *client.Client) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if dockerHost != "" {
+		opts = append(opts, client.WithHost(dockerHost))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		log.Println(err)
 	}
 	return
 }
 
-// Container operations
-func Container(action string, params string) {
+// Container operations, secrets and configs of opts are delivered to created container
+func Container(action string, params string, opts Options) {
 	cli := dockerCli()
 	args := strings.Split(params, " ")
 	switch action {
 	case "create":
-		imageName := args[1]
-		if ImageExist(imageName) != true {
-			out, err := cli.ImagePull(ctx, imageName, types.ImagePullOptions{})
-			if err != nil {
-				log.Println(err)
-			}
-			io.Copy(os.Stdout, out)
-		}
+		// "name image rs [service task namespace cpus memory]"
 		contName := args[0]
-		resp, err := cli.ContainerCreate(ctx, &container.Config{
-			Image: imageName,
-		}, nil, nil, contName)
+		id, err := runContainer(cli, contName, args[1], args[3:], opts)
 		if err != nil {
-			log.Println(err)
+			opts.status(contName, PhaseFailed, err.Error(), "")
+			return
 		}
-		if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-			log.Println(err)
-		}
-		fmt.Println(resp.ID)
+		opts.status(contName, PhaseStarted, id, "")
+		fmt.Println(id)
 	case "recreate":
+		// "old new image [service task namespace cpus memory]"
 		oldContName := args[0]
 		contName := args[1]
-		imageName := args[2]
-		if err := cli.ContainerRemove(ctx, GetContID(oldContName), types.ContainerRemoveOptions{}); err != nil {
-			log.Println(err)
-		}
-		// contName := nameWithSuffix(oldContName[:len(oldContName)-21])
-		resp, err := cli.ContainerCreate(ctx, &container.Config{
-			Image: imageName,
-		}, nil, nil, contName)
+		// old container is removed only when replacement runs, failed recreate leaves it as it was
+		id, err := runContainer(cli, contName, args[2], args[3:], opts)
 		if err != nil {
-			log.Println(err)
+			opts.status(contName, PhaseFailed, err.Error(), "")
+			return
 		}
-		if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-			log.Println(err)
+		if oldID := GetContID(oldContName); oldID != "" {
+			if err := cli.ContainerRemove(ctx, oldID, types.ContainerRemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+				log.Printf("Failed to remove %v replaced by %v: %v", oldContName, contName, err)
+			}
 		}
-		fmt.Println(resp.ID)
+		removeSecrets(oldContName)
+		removeConfigs(oldContName)
+		opts.status(contName, PhaseStarted, id, "")
+		fmt.Println(id)
 	case "delete":
 		containerName := args[0]
 		if err := cli.ContainerStop(ctx, GetContID(containerName), nil); err != nil {
@@ -79,13 +138,91 @@ func Container(action string, params string) {
 		if err := cli.ContainerRemove(ctx, GetContID(containerName), types.ContainerRemoveOptions{}); err != nil {
 			log.Println(err)
 		}
+		removeSecrets(containerName)
+		removeConfigs(containerName)
 	default:
 		fmt.Println("Wrong action")
 	}
 }
 
+// runContainer - pull image, deliver secrets and configs, create and start container from optional trailing
+// "service task namespace cpus memory" args, nothing of it is left behind on failure
+func runContainer(cli *client.Client, contName, imageName string, args []string, opts Options) (id string, err error) {
+	if err = pullImage(cli, contName, imageName, opts); err != nil {
+		log.Printf("Failed to pull %v: %v", imageName, err)
+		return
+	}
+	opts.status(contName, PhasePulled, imageName, imageDigest(imageName))
+	hostConfig := taskHostConfig(args...)
+	if err = mountSecrets(hostConfig, contName, opts.Secrets); err != nil {
+		log.Printf("Failed to mount secrets of %v: %v", contName, err)
+		return
+	}
+	if err = mountConfigs(hostConfig, contName, opts.Configs); err != nil {
+		log.Printf("Failed to mount configs of %v: %v", contName, err)
+		removeSecrets(contName)
+		return
+	}
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:  imageName,
+		Env:    secretsEnv(opts.Secrets),
+		Labels: managedLabels(args...),
+	}, hostConfig, nil, contName)
+	if err != nil {
+		log.Println(err)
+		removeSecrets(contName)
+		removeConfigs(contName)
+		return
+	}
+	if err = cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		log.Println(err)
+		cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+		removeSecrets(contName)
+		removeConfigs(contName)
+		return
+	}
+	return resp.ID, nil
+}
+
+// IsManaged - check if container was created by dockerator
+func IsManaged(container types.Container) bool {
+	return container.Labels[ManagedLabel] == "true"
+}
+
+// Events - stream of container events from Docker daemon
+func Events(ctx context.Context) (<-chan events.Message, <-chan error) {
+	cli := dockerCli()
+	f := filters.NewArgs()
+	f.Add("type", "container")
+	return cli.Events(ctx, types.EventsOptions{Filters: f})
+}
+
+// ContainerState - current state and exit code of container
+func ContainerState(id string) (state string, exitCode int) {
+	cli := dockerCli()
+	container, err := cli.ContainerInspect(ctx, id)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	return container.State.Status, container.State.ExitCode
+}
+
+// Inspect - low level details of container
+func Inspect(id string) (types.ContainerJSON, error) {
+	cli := dockerCli()
+	return cli.ContainerInspect(ctx, id)
+}
+
+// Info - Docker daemon info, used for node stats
+func Info() (types.Info, error) {
+	cli := dockerCli()
+	return cli.Info(ctx)
+}
+
 // GetNodeMap - get map of nodes and IP
 func GetNodeMap() (nodes map[string]string) {
+	nodes = map[string]string{}
 	cli := dockerCli()
 	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
 	if err != nil {
@@ -309,17 +446,7 @@ func GetNodeIP(iface string) (ip string) {
 func ImageExist(imageName string) (result bool) {
 	cli := dockerCli()
 
-	images, err := cli.ImageList(context.Background(), types.ImageListOptions{})
-	if err != nil {
-		panic(err)
-	}
-	result = false
-	for _, image := range images {
-		for _, tag := range image.RepoTags {
-			if tag == imageName {
-				result = true
-			}
-		}
-	}
-	return
+	// inspect resolves implicit latest tag and digest references
+	_, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+	return err == nil
 }
@@ -0,0 +1,96 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// Pull policies of service images, if-not-present is default
+const (
+	PullAlways       = "always"
+	PullIfNotPresent = "if-not-present"
+	PullNever        = "never"
+)
+
+// Phases of task reported through Options.Status
+const (
+	PhasePulling = "pulling"
+	PhasePulled  = "pulled"
+	PhaseStarted = "started"
+	PhaseFailed  = "failed"
+)
+
+// pullMessage - line of Docker pull progress stream
+type pullMessage struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Progress string `json:"progress"`
+	Error    string `json:"error"`
+}
+
+// status - report phase of container task if caller listens
+func (o Options) status(contName, phase, detail, digest string) {
+	if o.Status != nil {
+		o.Status(contName, phase, detail, digest)
+	}
+}
+
+// pullImage - make image present according to pull policy, progress is reported every 2s
+func pullImage(cli *client.Client, contName, image string, opts Options) error {
+	present := ImageExist(image)
+	switch {
+	case opts.PullPolicy == PullNever && !present:
+		return fmt.Errorf("image %v is not present and pull policy is never", image)
+	case opts.PullPolicy != PullAlways && present:
+		return nil
+	}
+	opts.status(contName, PhasePulling, image, "")
+	out, err := cli.ImagePull(ctx, image, types.ImagePullOptions{RegistryAuth: registryAuth(opts.Registry)})
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	dec := json.NewDecoder(out)
+	last := time.Now()
+	for {
+		m := pullMessage{}
+		if err := dec.Decode(&m); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if m.Error != "" {
+			return errors.New(m.Error)
+		}
+		if time.Since(last) > 2*time.Second {
+			last = time.Now()
+			opts.status(contName, PhasePulling, strings.TrimSpace(fmt.Sprintf("%v %v %v", m.ID, m.Status, m.Progress)), "")
+		}
+	}
+}
+
+// imageDigest - registry digest of local image, empty for images not pulled from registry
+func imageDigest(image string) string {
+	info, _, err := dockerCli().ImageInspectWithRaw(ctx, image)
+	if err != nil || len(info.RepoDigests) == 0 {
+		return ""
+	}
+	digest := info.RepoDigests[0]
+	repo := strings.SplitN(image, "@", 2)[0]
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	for _, d := range info.RepoDigests {
+		if strings.HasPrefix(d, repo+"@") {
+			digest = d
+		}
+	}
+	return digest[strings.Index(digest, "@")+1:]
+}
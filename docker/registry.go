@@ -0,0 +1,38 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Registry - credential of private registry used to pull image
+type Registry struct {
+	Server   string
+	Username string
+	Password string
+}
+
+// Options - payload delivered with task to created container, Status gets task phases
+type Options struct {
+	Secrets    []Secret
+	Configs    []Config
+	Registry   *Registry
+	PullPolicy string
+	Status     func(container, phase, detail, digest string)
+}
+
+// registryAuth - encoded X-Registry-Auth of credential, empty for public images
+func registryAuth(r *Registry) string {
+	if r == nil {
+		return ""
+	}
+	data, err := json.Marshal(types.AuthConfig{Username: r.Username, Password: r.Password, ServerAddress: r.Server})
+	if err != nil {
+		log.Printf("Failed to encode registry auth for %v: %v", r.Server, err)
+		return ""
+	}
+	return base64.URLEncoding.EncodeToString(data)
+}
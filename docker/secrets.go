@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Secret - decrypted secret delivered to container as file and/or env variable
+type Secret struct {
+	Name  string
+	Value []byte
+	Env   string
+	File  string
+}
+
+// secretsDir - host directory keeping secret files of containers, should be tmpfs
+var secretsDir = "/run/dockerator/secrets"
+
+// SetSecretsDir - keep secret files of containers in dir
+func SetSecretsDir(dir string) {
+	secretsDir = dir
+}
+
+// secretsEnv - env variables of secrets delivered that way
+func secretsEnv(secrets []Secret) (env []string) {
+	for _, s := range secrets {
+		if s.Env != "" {
+			env = append(env, s.Env+"="+string(s.Value))
+		}
+	}
+	return
+}
+
+// mountSecrets - write secret files of container and mount them read only at /run/secrets, container
+// must not start without them
+func mountSecrets(hostConfig *container.HostConfig, contName string, secrets []Secret) error {
+	files := []Secret{}
+	for _, s := range secrets {
+		if s.File != "" || s.Env == "" {
+			files = append(files, s)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	dir := filepath.Join(secretsDir, contName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create secrets dir: %v", err)
+	}
+	for _, s := range files {
+		name := s.File
+		if name == "" {
+			name = s.Name
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.Base(name)), s.Value, 0400); err != nil {
+			removeSecrets(contName)
+			return fmt.Errorf("failed to write secret %v: %v", s.Name, err)
+		}
+	}
+	hostConfig.Binds = append(hostConfig.Binds, dir+":/run/secrets:ro")
+	return nil
+}
+
+// removeSecrets - drop secret files of removed container
+func removeSecrets(contName string) {
+	if err := os.RemoveAll(filepath.Join(secretsDir, contName)); err != nil {
+		log.Printf("Failed to remove secrets of %v: %v", contName, err)
+	}
+}
@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Stats - resource usage of container, network and block IO are totals since start
+type Stats struct {
+	CPUPercent  float64
+	Memory      int64
+	MemoryLimit int64
+	NetRx       int64
+	NetTx       int64
+	BlockRead   int64
+	BlockWrite  int64
+}
+
+// ContainerStats - one sample of Docker stats API, CPU is percent of one core like `docker stats`
+func ContainerStats(id string) (s Stats, err error) {
+	resp, err := dockerCli().ContainerStats(ctx, id, false)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	v := types.StatsJSON{}
+	if err = json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return
+	}
+	cpuDelta := float64(v.CPUStats.CPUUsage.TotalUsage) - float64(v.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(v.CPUStats.SystemUsage) - float64(v.PreCPUStats.SystemUsage)
+	cpus := float64(v.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(v.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		s.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+	// page cache is not counted, "inactive_file" on cgroup v2
+	s.Memory = int64(v.MemoryStats.Usage)
+	for _, cache := range []string{"inactive_file", "total_inactive_file"} {
+		if c, ok := v.MemoryStats.Stats[cache]; ok && int64(c) < s.Memory {
+			s.Memory -= int64(c)
+			break
+		}
+	}
+	s.MemoryLimit = int64(v.MemoryStats.Limit)
+	for _, n := range v.Networks {
+		s.NetRx += int64(n.RxBytes)
+		s.NetTx += int64(n.TxBytes)
+	}
+	for _, b := range v.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(b.Op) {
+		case "read":
+			s.BlockRead += int64(b.Value)
+		case "write":
+			s.BlockWrite += int64(b.Value)
+		}
+	}
+	return
+}
@@ -28,6 +28,9 @@ type Request struct {
 	Node                 string   `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
 	Service              string   `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
 	State                string   `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Managed              bool     `protobuf:"varint,4,opt,name=managed,proto3" json:"managed,omitempty"`
+	Event                string   `protobuf:"bytes,5,opt,name=event,proto3" json:"event,omitempty"`
+	ExitCode             int32    `protobuf:"varint,6,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -79,174 +82,1225 @@ func (m *Request) GetState() string {
 	return ""
 }
 
+func (m *Request) GetManaged() bool {
+	if m != nil {
+		return m.Managed
+	}
+	return false
+}
+
+func (m *Request) GetEvent() string {
+	if m != nil {
+		return m.Event
+	}
+	return ""
+}
+
+func (m *Request) GetExitCode() int32 {
+	if m != nil {
+		return m.ExitCode
+	}
+	return 0
+}
+
+type Secret struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Env                  string   `protobuf:"bytes,3,opt,name=env,proto3" json:"env,omitempty"`
+	File                 string   `protobuf:"bytes,4,opt,name=file,proto3" json:"file,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Secret) Reset()         { *m = Secret{} }
+func (m *Secret) String() string { return proto.CompactTextString(m) }
+func (*Secret) ProtoMessage()    {}
+func (*Secret) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{1}
+}
+
+func (m *Secret) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Secret.Unmarshal(m, b)
+}
+func (m *Secret) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Secret.Marshal(b, m, deterministic)
+}
+func (m *Secret) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Secret.Merge(m, src)
+}
+func (m *Secret) XXX_Size() int {
+	return xxx_messageInfo_Secret.Size(m)
+}
+func (m *Secret) XXX_DiscardUnknown() {
+	xxx_messageInfo_Secret.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Secret proto.InternalMessageInfo
+
+func (m *Secret) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Secret) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *Secret) GetEnv() string {
+	if m != nil {
+		return m.Env
+	}
+	return ""
+}
+
+func (m *Secret) GetFile() string {
+	if m != nil {
+		return m.File
+	}
+	return ""
+}
+
+type Config struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Path                 string   `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	Version              int64    `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Config) Reset()         { *m = Config{} }
+func (m *Config) String() string { return proto.CompactTextString(m) }
+func (*Config) ProtoMessage()    {}
+func (*Config) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{2}
+}
+
+func (m *Config) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Config.Unmarshal(m, b)
+}
+func (m *Config) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Config.Marshal(b, m, deterministic)
+}
+func (m *Config) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Config.Merge(m, src)
+}
+func (m *Config) XXX_Size() int {
+	return xxx_messageInfo_Config.Size(m)
+}
+func (m *Config) XXX_DiscardUnknown() {
+	xxx_messageInfo_Config.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Config proto.InternalMessageInfo
+
+func (m *Config) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Config) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Config) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *Config) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type Registry struct {
+	Server               string   `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
+	Username             string   `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Password             string   `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Registry) Reset()         { *m = Registry{} }
+func (m *Registry) String() string { return proto.CompactTextString(m) }
+func (*Registry) ProtoMessage()    {}
+func (*Registry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{3}
+}
+
+func (m *Registry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Registry.Unmarshal(m, b)
+}
+func (m *Registry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Registry.Marshal(b, m, deterministic)
+}
+func (m *Registry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Registry.Merge(m, src)
+}
+func (m *Registry) XXX_Size() int {
+	return xxx_messageInfo_Registry.Size(m)
+}
+func (m *Registry) XXX_DiscardUnknown() {
+	xxx_messageInfo_Registry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Registry proto.InternalMessageInfo
+
+func (m *Registry) GetServer() string {
+	if m != nil {
+		return m.Server
+	}
+	return ""
+}
+
+func (m *Registry) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *Registry) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
 type Response struct {
-	Command              string   `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
-	Params               string   `protobuf:"bytes,2,opt,name=params,proto3" json:"params,omitempty"`
-	Status               bool     `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+	Command              string    `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Params               string    `protobuf:"bytes,2,opt,name=params,proto3" json:"params,omitempty"`
+	Status               bool      `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+	Secrets              []*Secret `protobuf:"bytes,4,rep,name=secrets,proto3" json:"secrets,omitempty"`
+	Configs              []*Config `protobuf:"bytes,5,rep,name=configs,proto3" json:"configs,omitempty"`
+	Registry             *Registry `protobuf:"bytes,6,opt,name=registry,proto3" json:"registry,omitempty"`
+	PullPolicy           string    `protobuf:"bytes,7,opt,name=pull_policy,json=pullPolicy,proto3" json:"pull_policy,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+func (*Response) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{4}
+}
+
+func (m *Response) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Response.Unmarshal(m, b)
+}
+func (m *Response) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Response.Marshal(b, m, deterministic)
+}
+func (m *Response) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Response.Merge(m, src)
+}
+func (m *Response) XXX_Size() int {
+	return xxx_messageInfo_Response.Size(m)
+}
+func (m *Response) XXX_DiscardUnknown() {
+	xxx_messageInfo_Response.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Response proto.InternalMessageInfo
+
+func (m *Response) GetCommand() string {
+	if m != nil {
+		return m.Command
+	}
+	return ""
+}
+
+func (m *Response) GetParams() string {
+	if m != nil {
+		return m.Params
+	}
+	return ""
+}
+
+func (m *Response) GetStatus() bool {
+	if m != nil {
+		return m.Status
+	}
+	return false
+}
+
+func (m *Response) GetSecrets() []*Secret {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *Response) GetConfigs() []*Config {
+	if m != nil {
+		return m.Configs
+	}
+	return nil
+}
+
+func (m *Response) GetRegistry() *Registry {
+	if m != nil {
+		return m.Registry
+	}
+	return nil
+}
+
+func (m *Response) GetPullPolicy() string {
+	if m != nil {
+		return m.PullPolicy
+	}
+	return ""
+}
+
+type TaskRequest struct {
+	Node                 string   `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TaskRequest) Reset()         { *m = TaskRequest{} }
+func (m *TaskRequest) String() string { return proto.CompactTextString(m) }
+func (*TaskRequest) ProtoMessage()    {}
+func (*TaskRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{5}
+}
+
+func (m *TaskRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskRequest.Unmarshal(m, b)
+}
+func (m *TaskRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskRequest.Marshal(b, m, deterministic)
+}
+func (m *TaskRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskRequest.Merge(m, src)
+}
+func (m *TaskRequest) XXX_Size() int {
+	return xxx_messageInfo_TaskRequest.Size(m)
+}
+func (m *TaskRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskRequest proto.InternalMessageInfo
+
+func (m *TaskRequest) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+type TaskResponse struct {
+	Job                  string    `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	Params               string    `protobuf:"bytes,2,opt,name=params,proto3" json:"params,omitempty"`
+	Secrets              []*Secret `protobuf:"bytes,3,rep,name=secrets,proto3" json:"secrets,omitempty"`
+	Configs              []*Config `protobuf:"bytes,4,rep,name=configs,proto3" json:"configs,omitempty"`
+	Registry             *Registry `protobuf:"bytes,5,opt,name=registry,proto3" json:"registry,omitempty"`
+	PullPolicy           string    `protobuf:"bytes,6,opt,name=pull_policy,json=pullPolicy,proto3" json:"pull_policy,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *TaskResponse) Reset()         { *m = TaskResponse{} }
+func (m *TaskResponse) String() string { return proto.CompactTextString(m) }
+func (*TaskResponse) ProtoMessage()    {}
+func (*TaskResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{6}
+}
+
+func (m *TaskResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskResponse.Unmarshal(m, b)
+}
+func (m *TaskResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskResponse.Marshal(b, m, deterministic)
+}
+func (m *TaskResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskResponse.Merge(m, src)
+}
+func (m *TaskResponse) XXX_Size() int {
+	return xxx_messageInfo_TaskResponse.Size(m)
+}
+func (m *TaskResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskResponse proto.InternalMessageInfo
+
+func (m *TaskResponse) GetJob() string {
+	if m != nil {
+		return m.Job
+	}
+	return ""
+}
+
+func (m *TaskResponse) GetParams() string {
+	if m != nil {
+		return m.Params
+	}
+	return ""
+}
+
+func (m *TaskResponse) GetSecrets() []*Secret {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *TaskResponse) GetConfigs() []*Config {
+	if m != nil {
+		return m.Configs
+	}
+	return nil
+}
+
+func (m *TaskResponse) GetRegistry() *Registry {
+	if m != nil {
+		return m.Registry
+	}
+	return nil
+}
+
+func (m *TaskResponse) GetPullPolicy() string {
+	if m != nil {
+		return m.PullPolicy
+	}
+	return ""
+}
+
+type TaskStatus struct {
+	Node                 string   `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Container            string   `protobuf:"bytes,2,opt,name=container,proto3" json:"container,omitempty"`
+	Phase                string   `protobuf:"bytes,3,opt,name=phase,proto3" json:"phase,omitempty"`
+	Detail               string   `protobuf:"bytes,4,opt,name=detail,proto3" json:"detail,omitempty"`
+	Digest               string   `protobuf:"bytes,5,opt,name=digest,proto3" json:"digest,omitempty"`
+	Timestamp            int64    `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TaskStatus) Reset()         { *m = TaskStatus{} }
+func (m *TaskStatus) String() string { return proto.CompactTextString(m) }
+func (*TaskStatus) ProtoMessage()    {}
+func (*TaskStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{7}
+}
+
+func (m *TaskStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskStatus.Unmarshal(m, b)
+}
+func (m *TaskStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskStatus.Marshal(b, m, deterministic)
+}
+func (m *TaskStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskStatus.Merge(m, src)
+}
+func (m *TaskStatus) XXX_Size() int {
+	return xxx_messageInfo_TaskStatus.Size(m)
+}
+func (m *TaskStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskStatus proto.InternalMessageInfo
+
+func (m *TaskStatus) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *TaskStatus) GetContainer() string {
+	if m != nil {
+		return m.Container
+	}
+	return ""
+}
+
+func (m *TaskStatus) GetPhase() string {
+	if m != nil {
+		return m.Phase
+	}
+	return ""
+}
+
+func (m *TaskStatus) GetDetail() string {
+	if m != nil {
+		return m.Detail
+	}
+	return ""
+}
+
+func (m *TaskStatus) GetDigest() string {
+	if m != nil {
+		return m.Digest
+	}
+	return ""
+}
+
+func (m *TaskStatus) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type TaskStatusResponse struct {
+	Known                bool     `protobuf:"varint,1,opt,name=known,proto3" json:"known,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TaskStatusResponse) Reset()         { *m = TaskStatusResponse{} }
+func (m *TaskStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*TaskStatusResponse) ProtoMessage()    {}
+func (*TaskStatusResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{8}
+}
+
+func (m *TaskStatusResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskStatusResponse.Unmarshal(m, b)
+}
+func (m *TaskStatusResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskStatusResponse.Marshal(b, m, deterministic)
+}
+func (m *TaskStatusResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskStatusResponse.Merge(m, src)
+}
+func (m *TaskStatusResponse) XXX_Size() int {
+	return xxx_messageInfo_TaskStatusResponse.Size(m)
+}
+func (m *TaskStatusResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskStatusResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskStatusResponse proto.InternalMessageInfo
+
+func (m *TaskStatusResponse) GetKnown() bool {
+	if m != nil {
+		return m.Known
+	}
+	return false
+}
+
+type HeartbeatRequest struct {
+	Node                 string   `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Ip                   string   `protobuf:"bytes,2,opt,name=ip,proto3" json:"ip,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatRequest) Reset()         { *m = HeartbeatRequest{} }
+func (m *HeartbeatRequest) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatRequest) ProtoMessage()    {}
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{9}
+}
+
+func (m *HeartbeatRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatRequest.Unmarshal(m, b)
+}
+func (m *HeartbeatRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatRequest.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatRequest.Merge(m, src)
+}
+func (m *HeartbeatRequest) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatRequest.Size(m)
+}
+func (m *HeartbeatRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatRequest proto.InternalMessageInfo
+
+func (m *HeartbeatRequest) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *HeartbeatRequest) GetIp() string {
+	if m != nil {
+		return m.Ip
+	}
+	return ""
+}
+
+type HeartbeatResponse struct {
+	Ttl                  int64    `protobuf:"varint,1,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Status               bool     `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatResponse) Reset()         { *m = HeartbeatResponse{} }
+func (m *HeartbeatResponse) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatResponse) ProtoMessage()    {}
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{10}
+}
+
+func (m *HeartbeatResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatResponse.Unmarshal(m, b)
+}
+func (m *HeartbeatResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatResponse.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatResponse.Merge(m, src)
+}
+func (m *HeartbeatResponse) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatResponse.Size(m)
+}
+func (m *HeartbeatResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatResponse proto.InternalMessageInfo
+
+func (m *HeartbeatResponse) GetTtl() int64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+func (m *HeartbeatResponse) GetStatus() bool {
+	if m != nil {
+		return m.Status
+	}
+	return false
+}
+
+type ContainerStatus struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Image                string   `protobuf:"bytes,3,opt,name=image,proto3" json:"image,omitempty"`
+	State                string   `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
+	Health               string   `protobuf:"bytes,5,opt,name=health,proto3" json:"health,omitempty"`
+	ExitCode             int32    `protobuf:"varint,6,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	StartedAt            int64    `protobuf:"varint,7,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	Managed              bool     `protobuf:"varint,8,opt,name=managed,proto3" json:"managed,omitempty"`
+	Service              string   `protobuf:"bytes,9,opt,name=service,proto3" json:"service,omitempty"`
+	Task                 string   `protobuf:"bytes,10,opt,name=task,proto3" json:"task,omitempty"`
+	CpuPercent           float64  `protobuf:"fixed64,11,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	MemoryUsage          int64    `protobuf:"varint,12,opt,name=memory_usage,json=memoryUsage,proto3" json:"memory_usage,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ContainerStatus) Reset()         { *m = ContainerStatus{} }
+func (m *ContainerStatus) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatus) ProtoMessage()    {}
+func (*ContainerStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{11}
+}
+
+func (m *ContainerStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ContainerStatus.Unmarshal(m, b)
+}
+func (m *ContainerStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ContainerStatus.Marshal(b, m, deterministic)
+}
+func (m *ContainerStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ContainerStatus.Merge(m, src)
+}
+func (m *ContainerStatus) XXX_Size() int {
+	return xxx_messageInfo_ContainerStatus.Size(m)
+}
+func (m *ContainerStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_ContainerStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ContainerStatus proto.InternalMessageInfo
+
+func (m *ContainerStatus) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ContainerStatus) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ContainerStatus) GetImage() string {
+	if m != nil {
+		return m.Image
+	}
+	return ""
+}
+
+func (m *ContainerStatus) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *ContainerStatus) GetHealth() string {
+	if m != nil {
+		return m.Health
+	}
+	return ""
+}
+
+func (m *ContainerStatus) GetExitCode() int32 {
+	if m != nil {
+		return m.ExitCode
+	}
+	return 0
+}
+
+func (m *ContainerStatus) GetStartedAt() int64 {
+	if m != nil {
+		return m.StartedAt
+	}
+	return 0
+}
+
+func (m *ContainerStatus) GetManaged() bool {
+	if m != nil {
+		return m.Managed
+	}
+	return false
+}
+
+func (m *ContainerStatus) GetService() string {
+	if m != nil {
+		return m.Service
+	}
+	return ""
+}
+
+func (m *ContainerStatus) GetTask() string {
+	if m != nil {
+		return m.Task
+	}
+	return ""
+}
+
+func (m *ContainerStatus) GetCpuPercent() float64 {
+	if m != nil {
+		return m.CpuPercent
+	}
+	return 0
+}
+
+func (m *ContainerStatus) GetMemoryUsage() int64 {
+	if m != nil {
+		return m.MemoryUsage
+	}
+	return 0
+}
+
+type NodeStats struct {
+	Cpus                 int32    `protobuf:"varint,1,opt,name=cpus,proto3" json:"cpus,omitempty"`
+	Memory               int64    `protobuf:"varint,2,opt,name=memory,proto3" json:"memory,omitempty"`
+	Containers           int32    `protobuf:"varint,3,opt,name=containers,proto3" json:"containers,omitempty"`
+	Running              int32    `protobuf:"varint,4,opt,name=running,proto3" json:"running,omitempty"`
+	CpuPercent           float64  `protobuf:"fixed64,5,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	MemoryUsage          int64    `protobuf:"varint,6,opt,name=memory_usage,json=memoryUsage,proto3" json:"memory_usage,omitempty"`
+	NetRx                int64    `protobuf:"varint,7,opt,name=net_rx,json=netRx,proto3" json:"net_rx,omitempty"`
+	NetTx                int64    `protobuf:"varint,8,opt,name=net_tx,json=netTx,proto3" json:"net_tx,omitempty"`
+	BlockRead            int64    `protobuf:"varint,9,opt,name=block_read,json=blockRead,proto3" json:"block_read,omitempty"`
+	BlockWrite           int64    `protobuf:"varint,10,opt,name=block_write,json=blockWrite,proto3" json:"block_write,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *NodeStats) Reset()         { *m = NodeStats{} }
+func (m *NodeStats) String() string { return proto.CompactTextString(m) }
+func (*NodeStats) ProtoMessage()    {}
+func (*NodeStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{12}
+}
+
+func (m *NodeStats) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_NodeStats.Unmarshal(m, b)
+}
+func (m *NodeStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_NodeStats.Marshal(b, m, deterministic)
+}
+func (m *NodeStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NodeStats.Merge(m, src)
+}
+func (m *NodeStats) XXX_Size() int {
+	return xxx_messageInfo_NodeStats.Size(m)
+}
+func (m *NodeStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_NodeStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NodeStats proto.InternalMessageInfo
+
+func (m *NodeStats) GetCpus() int32 {
+	if m != nil {
+		return m.Cpus
+	}
+	return 0
+}
+
+func (m *NodeStats) GetMemory() int64 {
+	if m != nil {
+		return m.Memory
+	}
+	return 0
+}
+
+func (m *NodeStats) GetContainers() int32 {
+	if m != nil {
+		return m.Containers
+	}
+	return 0
+}
+
+func (m *NodeStats) GetRunning() int32 {
+	if m != nil {
+		return m.Running
+	}
+	return 0
+}
+
+func (m *NodeStats) GetCpuPercent() float64 {
+	if m != nil {
+		return m.CpuPercent
+	}
+	return 0
+}
+
+func (m *NodeStats) GetMemoryUsage() int64 {
+	if m != nil {
+		return m.MemoryUsage
+	}
+	return 0
+}
+
+func (m *NodeStats) GetNetRx() int64 {
+	if m != nil {
+		return m.NetRx
+	}
+	return 0
+}
+
+func (m *NodeStats) GetNetTx() int64 {
+	if m != nil {
+		return m.NetTx
+	}
+	return 0
+}
+
+func (m *NodeStats) GetBlockRead() int64 {
+	if m != nil {
+		return m.BlockRead
+	}
+	return 0
+}
+
+func (m *NodeStats) GetBlockWrite() int64 {
+	if m != nil {
+		return m.BlockWrite
+	}
+	return 0
+}
+
+type NodeStatusReport struct {
+	Node                 string             `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Ip                   string             `protobuf:"bytes,2,opt,name=ip,proto3" json:"ip,omitempty"`
+	Containers           []*ContainerStatus `protobuf:"bytes,3,rep,name=containers,proto3" json:"containers,omitempty"`
+	Stats                *NodeStats         `protobuf:"bytes,4,opt,name=stats,proto3" json:"stats,omitempty"`
+	Timestamp            int64              `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *NodeStatusReport) Reset()         { *m = NodeStatusReport{} }
+func (m *NodeStatusReport) String() string { return proto.CompactTextString(m) }
+func (*NodeStatusReport) ProtoMessage()    {}
+func (*NodeStatusReport) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{13}
+}
+
+func (m *NodeStatusReport) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_NodeStatusReport.Unmarshal(m, b)
+}
+func (m *NodeStatusReport) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_NodeStatusReport.Marshal(b, m, deterministic)
+}
+func (m *NodeStatusReport) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NodeStatusReport.Merge(m, src)
+}
+func (m *NodeStatusReport) XXX_Size() int {
+	return xxx_messageInfo_NodeStatusReport.Size(m)
+}
+func (m *NodeStatusReport) XXX_DiscardUnknown() {
+	xxx_messageInfo_NodeStatusReport.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NodeStatusReport proto.InternalMessageInfo
+
+func (m *NodeStatusReport) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *NodeStatusReport) GetIp() string {
+	if m != nil {
+		return m.Ip
+	}
+	return ""
+}
+
+func (m *NodeStatusReport) GetContainers() []*ContainerStatus {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+func (m *NodeStatusReport) GetStats() *NodeStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+func (m *NodeStatusReport) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type NodeStatusResponse struct {
+	Commands             []*Response `protobuf:"bytes,1,rep,name=commands,proto3" json:"commands,omitempty"`
+	Registered           bool        `protobuf:"varint,2,opt,name=registered,proto3" json:"registered,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *NodeStatusResponse) Reset()         { *m = NodeStatusResponse{} }
+func (m *NodeStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*NodeStatusResponse) ProtoMessage()    {}
+func (*NodeStatusResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{14}
+}
+
+func (m *NodeStatusResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_NodeStatusResponse.Unmarshal(m, b)
+}
+func (m *NodeStatusResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_NodeStatusResponse.Marshal(b, m, deterministic)
+}
+func (m *NodeStatusResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NodeStatusResponse.Merge(m, src)
+}
+func (m *NodeStatusResponse) XXX_Size() int {
+	return xxx_messageInfo_NodeStatusResponse.Size(m)
+}
+func (m *NodeStatusResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_NodeStatusResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NodeStatusResponse proto.InternalMessageInfo
+
+func (m *NodeStatusResponse) GetCommands() []*Response {
+	if m != nil {
+		return m.Commands
+	}
+	return nil
+}
+
+func (m *NodeStatusResponse) GetRegistered() bool {
+	if m != nil {
+		return m.Registered
+	}
+	return false
+}
+
+type JoinRequest struct {
+	Node                 string   `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Token                string   `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Csr                  []byte   `protobuf:"bytes,3,opt,name=csr,proto3" json:"csr,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *Response) Reset()         { *m = Response{} }
-func (m *Response) String() string { return proto.CompactTextString(m) }
-func (*Response) ProtoMessage()    {}
-func (*Response) Descriptor() ([]byte, []int) {
-	return fileDescriptor_51773407af17b204, []int{1}
+func (m *JoinRequest) Reset()         { *m = JoinRequest{} }
+func (m *JoinRequest) String() string { return proto.CompactTextString(m) }
+func (*JoinRequest) ProtoMessage()    {}
+func (*JoinRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{15}
 }
 
-func (m *Response) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_Response.Unmarshal(m, b)
+func (m *JoinRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JoinRequest.Unmarshal(m, b)
 }
-func (m *Response) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_Response.Marshal(b, m, deterministic)
+func (m *JoinRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JoinRequest.Marshal(b, m, deterministic)
 }
-func (m *Response) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Response.Merge(m, src)
+func (m *JoinRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JoinRequest.Merge(m, src)
 }
-func (m *Response) XXX_Size() int {
-	return xxx_messageInfo_Response.Size(m)
+func (m *JoinRequest) XXX_Size() int {
+	return xxx_messageInfo_JoinRequest.Size(m)
 }
-func (m *Response) XXX_DiscardUnknown() {
-	xxx_messageInfo_Response.DiscardUnknown(m)
+func (m *JoinRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_JoinRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_Response proto.InternalMessageInfo
+var xxx_messageInfo_JoinRequest proto.InternalMessageInfo
 
-func (m *Response) GetCommand() string {
+func (m *JoinRequest) GetNode() string {
 	if m != nil {
-		return m.Command
+		return m.Node
 	}
 	return ""
 }
 
-func (m *Response) GetParams() string {
+func (m *JoinRequest) GetToken() string {
 	if m != nil {
-		return m.Params
+		return m.Token
 	}
 	return ""
 }
 
-func (m *Response) GetStatus() bool {
+func (m *JoinRequest) GetCsr() []byte {
 	if m != nil {
-		return m.Status
+		return m.Csr
 	}
-	return false
+	return nil
 }
 
-type TaskRequest struct {
+type CertificateRequest struct {
 	Node                 string   `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Csr                  []byte   `protobuf:"bytes,2,opt,name=csr,proto3" json:"csr,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TaskRequest) Reset()         { *m = TaskRequest{} }
-func (m *TaskRequest) String() string { return proto.CompactTextString(m) }
-func (*TaskRequest) ProtoMessage()    {}
-func (*TaskRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_51773407af17b204, []int{2}
+func (m *CertificateRequest) Reset()         { *m = CertificateRequest{} }
+func (m *CertificateRequest) String() string { return proto.CompactTextString(m) }
+func (*CertificateRequest) ProtoMessage()    {}
+func (*CertificateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{16}
 }
 
-func (m *TaskRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_TaskRequest.Unmarshal(m, b)
+func (m *CertificateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CertificateRequest.Unmarshal(m, b)
 }
-func (m *TaskRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_TaskRequest.Marshal(b, m, deterministic)
+func (m *CertificateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CertificateRequest.Marshal(b, m, deterministic)
 }
-func (m *TaskRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TaskRequest.Merge(m, src)
+func (m *CertificateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CertificateRequest.Merge(m, src)
 }
-func (m *TaskRequest) XXX_Size() int {
-	return xxx_messageInfo_TaskRequest.Size(m)
+func (m *CertificateRequest) XXX_Size() int {
+	return xxx_messageInfo_CertificateRequest.Size(m)
 }
-func (m *TaskRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_TaskRequest.DiscardUnknown(m)
+func (m *CertificateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CertificateRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TaskRequest proto.InternalMessageInfo
+var xxx_messageInfo_CertificateRequest proto.InternalMessageInfo
 
-func (m *TaskRequest) GetNode() string {
+func (m *CertificateRequest) GetNode() string {
 	if m != nil {
 		return m.Node
 	}
 	return ""
 }
 
-type TaskResponse struct {
-	Job                  string   `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
-	Params               string   `protobuf:"bytes,2,opt,name=params,proto3" json:"params,omitempty"`
+func (m *CertificateRequest) GetCsr() []byte {
+	if m != nil {
+		return m.Csr
+	}
+	return nil
+}
+
+type CertificateResponse struct {
+	Certificate          []byte   `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Ca                   []byte   `protobuf:"bytes,2,opt,name=ca,proto3" json:"ca,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TaskResponse) Reset()         { *m = TaskResponse{} }
-func (m *TaskResponse) String() string { return proto.CompactTextString(m) }
-func (*TaskResponse) ProtoMessage()    {}
-func (*TaskResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_51773407af17b204, []int{3}
+func (m *CertificateResponse) Reset()         { *m = CertificateResponse{} }
+func (m *CertificateResponse) String() string { return proto.CompactTextString(m) }
+func (*CertificateResponse) ProtoMessage()    {}
+func (*CertificateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_51773407af17b204, []int{17}
 }
 
-func (m *TaskResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_TaskResponse.Unmarshal(m, b)
+func (m *CertificateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CertificateResponse.Unmarshal(m, b)
 }
-func (m *TaskResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_TaskResponse.Marshal(b, m, deterministic)
+func (m *CertificateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CertificateResponse.Marshal(b, m, deterministic)
 }
-func (m *TaskResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TaskResponse.Merge(m, src)
+func (m *CertificateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CertificateResponse.Merge(m, src)
 }
-func (m *TaskResponse) XXX_Size() int {
-	return xxx_messageInfo_TaskResponse.Size(m)
+func (m *CertificateResponse) XXX_Size() int {
+	return xxx_messageInfo_CertificateResponse.Size(m)
 }
-func (m *TaskResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_TaskResponse.DiscardUnknown(m)
+func (m *CertificateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CertificateResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TaskResponse proto.InternalMessageInfo
+var xxx_messageInfo_CertificateResponse proto.InternalMessageInfo
 
-func (m *TaskResponse) GetJob() string {
+func (m *CertificateResponse) GetCertificate() []byte {
 	if m != nil {
-		return m.Job
+		return m.Certificate
 	}
-	return ""
+	return nil
 }
 
-func (m *TaskResponse) GetParams() string {
+func (m *CertificateResponse) GetCa() []byte {
 	if m != nil {
-		return m.Params
+		return m.Ca
 	}
-	return ""
+	return nil
 }
 
 func init() {
 	proto.RegisterType((*Request)(nil), "dockerator.Request")
+	proto.RegisterType((*Secret)(nil), "dockerator.Secret")
+	proto.RegisterType((*Config)(nil), "dockerator.Config")
+	proto.RegisterType((*Registry)(nil), "dockerator.Registry")
 	proto.RegisterType((*Response)(nil), "dockerator.Response")
 	proto.RegisterType((*TaskRequest)(nil), "dockerator.TaskRequest")
 	proto.RegisterType((*TaskResponse)(nil), "dockerator.TaskResponse")
+	proto.RegisterType((*TaskStatus)(nil), "dockerator.TaskStatus")
+	proto.RegisterType((*TaskStatusResponse)(nil), "dockerator.TaskStatusResponse")
+	proto.RegisterType((*HeartbeatRequest)(nil), "dockerator.HeartbeatRequest")
+	proto.RegisterType((*HeartbeatResponse)(nil), "dockerator.HeartbeatResponse")
+	proto.RegisterType((*ContainerStatus)(nil), "dockerator.ContainerStatus")
+	proto.RegisterType((*NodeStats)(nil), "dockerator.NodeStats")
+	proto.RegisterType((*NodeStatusReport)(nil), "dockerator.NodeStatusReport")
+	proto.RegisterType((*NodeStatusResponse)(nil), "dockerator.NodeStatusResponse")
+	proto.RegisterType((*JoinRequest)(nil), "dockerator.JoinRequest")
+	proto.RegisterType((*CertificateRequest)(nil), "dockerator.CertificateRequest")
+	proto.RegisterType((*CertificateResponse)(nil), "dockerator.CertificateResponse")
 }
 
 func init() { proto.RegisterFile("dockerator.proto", fileDescriptor_51773407af17b204) }
 
 var fileDescriptor_51773407af17b204 = []byte{
-	// 247 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x51, 0xb1, 0x4e, 0xc3, 0x40,
-	0x0c, 0x25, 0x14, 0xda, 0xe2, 0x76, 0xa8, 0x4c, 0x05, 0xa7, 0x4e, 0x70, 0x13, 0x53, 0x07, 0x58,
-	0x10, 0x6b, 0x11, 0x1b, 0x4b, 0x54, 0x89, 0xf9, 0x9a, 0x58, 0x02, 0xa2, 0xc4, 0xc1, 0xbe, 0xf2,
-	0x17, 0xfc, 0x33, 0xba, 0xcb, 0x05, 0x82, 0x10, 0x6c, 0x7e, 0x2f, 0xcf, 0xef, 0xbd, 0xf8, 0x60,
-	0x51, 0x72, 0x51, 0x91, 0x38, 0xcf, 0xb2, 0x6e, 0x85, 0x3d, 0x23, 0x7c, 0x33, 0xf6, 0x11, 0x26,
-	0x39, 0xbd, 0xed, 0x49, 0x3d, 0x22, 0x1c, 0x35, 0x5c, 0x92, 0xc9, 0x2e, 0xb2, 0xab, 0x93, 0x3c,
-	0xce, 0x68, 0x60, 0xa2, 0x24, 0xef, 0x2f, 0x05, 0x99, 0xc3, 0x48, 0xf7, 0x10, 0x97, 0x70, 0xac,
-	0xde, 0x79, 0x32, 0xa3, 0xc8, 0x77, 0xc0, 0x6e, 0x61, 0x9a, 0x93, 0xb6, 0xdc, 0x68, 0xdc, 0x2d,
-	0xb8, 0xae, 0x5d, 0x53, 0x26, 0xcb, 0x1e, 0xe2, 0x19, 0x8c, 0x5b, 0x27, 0xae, 0xd6, 0x64, 0x9a,
-	0x50, 0xe0, 0x83, 0xcd, 0x5e, 0xa3, 0xe9, 0x34, 0x4f, 0xc8, 0x5e, 0xc2, 0x6c, 0xeb, 0xb4, 0xfa,
-	0xa7, 0xa8, 0xbd, 0x85, 0x79, 0x27, 0x49, 0xe1, 0x0b, 0x18, 0xbd, 0xf2, 0x2e, 0x49, 0xc2, 0xf8,
-	0x57, 0xe8, 0xf5, 0x47, 0x06, 0x70, 0xff, 0x75, 0x10, 0xbc, 0x83, 0xd9, 0xe6, 0x99, 0x8a, 0xea,
-	0x89, 0xa5, 0x22, 0xc1, 0xd3, 0xf5, 0xe0, 0x7c, 0xa9, 0xc0, 0x6a, 0xf9, 0x93, 0xec, 0x22, 0xed,
-	0x01, 0x6e, 0x60, 0x1e, 0x77, 0x1f, 0x58, 0x42, 0x19, 0x3c, 0x1f, 0xea, 0x06, 0x7f, 0xb0, 0x32,
-	0xbf, 0x3f, 0xf4, 0x26, 0xbb, 0x71, 0x7c, 0xa4, 0x9b, 0xcf, 0x00, 0x00, 0x00, 0xff, 0xff, 0xa0,
-	0x5d, 0x4b, 0xda, 0xb8, 0x01, 0x00, 0x00,
+	// 1117 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x56, 0xcd, 0x8e, 0x1b, 0x45,
+	0x10, 0x8e, 0x3d, 0x1e, 0xc7, 0x2e, 0x5b, 0x60, 0x3a, 0x9b, 0x65, 0xe4, 0x24, 0x1b, 0x67, 0x4e,
+	0x2b, 0x40, 0x51, 0xb4, 0x48, 0x1c, 0x82, 0x38, 0x80, 0x11, 0x3f, 0x11, 0x42, 0x51, 0x27, 0x51,
+	0x10, 0x07, 0xac, 0xde, 0x99, 0x5a, 0xef, 0x60, 0x7b, 0x7a, 0xe8, 0x6e, 0xef, 0xcf, 0x99, 0x77,
+	0xe0, 0xc0, 0x11, 0xde, 0x03, 0x89, 0xa7, 0xe1, 0x35, 0x50, 0x75, 0xf7, 0xfc, 0xd8, 0xde, 0x5d,
+	0x12, 0x6e, 0x5d, 0x5f, 0x57, 0x55, 0x57, 0x7d, 0xf5, 0x33, 0x03, 0xa3, 0x54, 0x26, 0x0b, 0x54,
+	0xc2, 0x48, 0xf5, 0xb8, 0x50, 0xd2, 0x48, 0x06, 0x35, 0x12, 0xff, 0xde, 0x82, 0xdb, 0x1c, 0x7f,
+	0x59, 0xa3, 0x36, 0x8c, 0x41, 0x27, 0x97, 0x29, 0x46, 0xad, 0x49, 0xeb, 0xb0, 0xcf, 0xed, 0x99,
+	0x45, 0x70, 0x5b, 0xa3, 0x3a, 0xcb, 0x12, 0x8c, 0xda, 0x16, 0x2e, 0x45, 0xb6, 0x07, 0xa1, 0x36,
+	0xc2, 0x60, 0x14, 0x58, 0xdc, 0x09, 0xa4, 0xbf, 0x12, 0xb9, 0x98, 0x63, 0x1a, 0x75, 0x26, 0xad,
+	0xc3, 0x1e, 0x2f, 0x45, 0xd2, 0xc7, 0x33, 0xcc, 0x4d, 0x14, 0x3a, 0x7d, 0x2b, 0xb0, 0x7b, 0xd0,
+	0xc7, 0x8b, 0xcc, 0xcc, 0x12, 0x7a, 0xb8, 0x3b, 0x69, 0x1d, 0x86, 0xbc, 0x47, 0xc0, 0x54, 0xa6,
+	0x18, 0xff, 0x00, 0xdd, 0x17, 0x98, 0x28, 0x74, 0xa1, 0x89, 0x55, 0x1d, 0x9a, 0x58, 0xd9, 0x00,
+	0xce, 0xc4, 0x72, 0xed, 0x02, 0x1b, 0x72, 0x27, 0xb0, 0x11, 0x04, 0x98, 0x9f, 0xf9, 0xa0, 0xe8,
+	0x48, 0xb6, 0x27, 0xd9, 0x12, 0x6d, 0x3c, 0x7d, 0x6e, 0xcf, 0xf1, 0x4f, 0xd0, 0x9d, 0xca, 0xfc,
+	0x24, 0x9b, 0x5f, 0xe9, 0x99, 0x41, 0x27, 0x15, 0x46, 0x78, 0xc7, 0xf6, 0x4c, 0x58, 0x21, 0xcc,
+	0xa9, 0x77, 0x6c, 0xcf, 0x94, 0xec, 0x19, 0x2a, 0x9d, 0xc9, 0xdc, 0x3a, 0x0f, 0x78, 0x29, 0xc6,
+	0x3f, 0x42, 0x8f, 0xe3, 0x3c, 0xd3, 0x46, 0x5d, 0xb2, 0x7d, 0xe8, 0x12, 0x67, 0xa8, 0xfc, 0x1b,
+	0x5e, 0x62, 0x63, 0xe8, 0xad, 0x35, 0x2a, 0xfb, 0xba, 0xe3, 0xb6, 0x92, 0xe9, 0xae, 0x10, 0x5a,
+	0x9f, 0x4b, 0x95, 0xfa, 0x17, 0x2b, 0x39, 0xfe, 0xb5, 0x4d, 0xce, 0x75, 0x21, 0x73, 0x6d, 0xf9,
+	0x4e, 0xe4, 0x6a, 0x25, 0xf2, 0xd4, 0x7b, 0x2f, 0x45, 0x7a, 0xb6, 0x10, 0x4a, 0xac, 0xb4, 0x77,
+	0xee, 0x25, 0x1b, 0x8e, 0x11, 0x66, 0xad, 0xad, 0xe3, 0x1e, 0xf7, 0x12, 0xfb, 0x88, 0x2a, 0x4d,
+	0x64, 0xeb, 0xa8, 0x33, 0x09, 0x0e, 0x07, 0x47, 0xec, 0x71, 0xa3, 0x73, 0x5c, 0x1d, 0x78, 0xa9,
+	0x42, 0xda, 0x89, 0x25, 0x50, 0x47, 0xe1, 0xae, 0xb6, 0xe3, 0x96, 0x97, 0x2a, 0xec, 0x09, 0xf4,
+	0x94, 0xa7, 0xc3, 0x16, 0x79, 0x70, 0xb4, 0xd7, 0x54, 0x2f, 0xa9, 0xe2, 0x95, 0x16, 0x7b, 0x08,
+	0x83, 0x62, 0xbd, 0x5c, 0xce, 0x0a, 0xb9, 0xcc, 0x92, 0xcb, 0xe8, 0xb6, 0x4d, 0x01, 0x08, 0x7a,
+	0x6e, 0x91, 0xf8, 0x11, 0x0c, 0x5e, 0x0a, 0xbd, 0xb8, 0xa1, 0x77, 0xe3, 0x7f, 0x5a, 0x30, 0x74,
+	0x3a, 0x9e, 0xac, 0x11, 0x04, 0x3f, 0xcb, 0x63, 0xaf, 0x43, 0xc7, 0x6b, 0x49, 0x6a, 0x90, 0x11,
+	0xbc, 0x15, 0x19, 0x9d, 0xb7, 0x23, 0x23, 0xfc, 0x3f, 0x64, 0x74, 0x77, 0xc8, 0xf8, 0xa3, 0x05,
+	0x40, 0x99, 0xbe, 0x70, 0xa5, 0xbc, 0x6a, 0x90, 0xef, 0x43, 0x3f, 0x91, 0xb9, 0x11, 0x59, 0x8e,
+	0xca, 0x27, 0x5b, 0x03, 0x34, 0x4b, 0xc5, 0xa9, 0xd0, 0xd5, 0x30, 0x5b, 0x81, 0xd8, 0x49, 0xd1,
+	0x88, 0x6c, 0xe9, 0x67, 0xc7, 0x4b, 0x16, 0xcf, 0xe6, 0xa8, 0xcb, 0x59, 0xf6, 0x12, 0xbd, 0x61,
+	0xb2, 0x15, 0x6a, 0x23, 0x56, 0x85, 0x8d, 0x32, 0xe0, 0x35, 0x10, 0x7f, 0x00, 0xac, 0x8e, 0xb1,
+	0xaa, 0xc9, 0x1e, 0x84, 0x8b, 0x5c, 0x9e, 0xe7, 0x36, 0xd8, 0x1e, 0x77, 0x42, 0xfc, 0x09, 0x8c,
+	0xbe, 0x41, 0xa1, 0xcc, 0x31, 0x0a, 0x73, 0xd3, 0x7a, 0x7a, 0x07, 0xda, 0x59, 0xe1, 0xd3, 0x69,
+	0x67, 0x45, 0xfc, 0x19, 0xbc, 0xd7, 0xb0, 0xab, 0xcb, 0x6e, 0xcc, 0xd2, 0xda, 0x05, 0x9c, 0x8e,
+	0x8d, 0x19, 0x68, 0x37, 0x67, 0x20, 0xfe, 0xbb, 0x0d, 0xef, 0x4e, 0x4b, 0x52, 0x3c, 0x99, 0xf4,
+	0x44, 0x39, 0x5c, 0xed, 0x2c, 0xad, 0x16, 0x46, 0x7b, 0x73, 0x15, 0x65, 0x2b, 0x31, 0xaf, 0xe8,
+	0xb3, 0x42, 0xbd, 0x21, 0x3b, 0xcd, 0x0d, 0xb9, 0x0f, 0xdd, 0x53, 0x14, 0x4b, 0x73, 0x5a, 0x92,
+	0xe7, 0xa4, 0x1b, 0x37, 0x21, 0x7b, 0x00, 0xa0, 0x8d, 0x50, 0x06, 0xd3, 0x99, 0x30, 0x76, 0x1a,
+	0x02, 0xde, 0xf7, 0xc8, 0xe7, 0xa6, 0xb9, 0x75, 0x7b, 0x9b, 0x5b, 0xb7, 0xb1, 0xbf, 0xfb, 0x9b,
+	0xfb, 0x9b, 0x41, 0xc7, 0x08, 0xbd, 0x88, 0xc0, 0xe5, 0x41, 0x67, 0x6a, 0xb4, 0xa4, 0x58, 0xcf,
+	0x0a, 0x54, 0x09, 0x6d, 0xea, 0xc1, 0xa4, 0x75, 0xd8, 0xe2, 0x90, 0x14, 0xeb, 0xe7, 0x0e, 0x61,
+	0x8f, 0x60, 0xb8, 0xc2, 0x95, 0x54, 0x97, 0xb3, 0xb5, 0xa6, 0x7c, 0x87, 0x36, 0x92, 0x81, 0xc3,
+	0x5e, 0x11, 0x14, 0xff, 0xd9, 0x86, 0xfe, 0xf7, 0x32, 0x45, 0xa2, 0xcf, 0xb6, 0x62, 0x52, 0xac,
+	0xb5, 0xe5, 0x2f, 0xe4, 0xf6, 0x4c, 0x0c, 0x38, 0x03, 0xcb, 0x61, 0xc0, 0xbd, 0xc4, 0x0e, 0x00,
+	0xaa, 0x8e, 0x74, 0xdb, 0x29, 0xe4, 0x0d, 0x84, 0x72, 0x51, 0xeb, 0x3c, 0xcf, 0xf2, 0xb9, 0x65,
+	0x34, 0xe4, 0xa5, 0xb8, 0x1d, 0x77, 0xf8, 0x9f, 0x71, 0x77, 0x77, 0xe2, 0x66, 0x77, 0xa1, 0x9b,
+	0xa3, 0x99, 0xa9, 0x0b, 0x4f, 0x6f, 0x98, 0xa3, 0xe1, 0x17, 0x25, 0x6c, 0x2e, 0xa2, 0x5e, 0x05,
+	0xbf, 0xbc, 0xa0, 0x82, 0x1c, 0x2f, 0x65, 0xb2, 0x98, 0x29, 0x14, 0xa9, 0xa5, 0x36, 0xe0, 0x7d,
+	0x8b, 0x70, 0x14, 0x29, 0x05, 0xe4, 0xae, 0xcf, 0x55, 0x66, 0xd0, 0x72, 0x1c, 0x70, 0x67, 0xf1,
+	0x9a, 0x90, 0xf8, 0xaf, 0x16, 0x8c, 0x4a, 0x96, 0x68, 0x1a, 0x0a, 0xa9, 0xde, 0xa8, 0xc3, 0xd9,
+	0xa7, 0x5b, 0x24, 0xd1, 0xba, 0xb9, 0xb7, 0xb5, 0x6e, 0x9a, 0xfd, 0xbb, 0xc1, 0xe0, 0x87, 0xae,
+	0x23, 0xb5, 0xe5, 0x6f, 0x70, 0x74, 0xb7, 0x69, 0x57, 0xd5, 0xcc, 0x35, 0xaa, 0xde, 0x9c, 0xe6,
+	0x70, 0x7b, 0x9a, 0x4f, 0x80, 0x35, 0xe3, 0xf7, 0xa3, 0xf6, 0x04, 0x7a, 0xfe, 0xfb, 0x43, 0x25,
+	0x0f, 0x76, 0x77, 0x9b, 0xd3, 0xe3, 0x95, 0x16, 0x15, 0xdd, 0xed, 0x39, 0x54, 0x98, 0xfa, 0x71,
+	0x6c, 0x20, 0xf1, 0xb7, 0x30, 0x78, 0x26, 0xb3, 0xfc, 0xa6, 0x25, 0xb0, 0x07, 0xa1, 0x91, 0x0b,
+	0xcc, 0x3d, 0x4b, 0x4e, 0xa0, 0xa9, 0x4f, 0xb4, 0xb2, 0x6d, 0x34, 0xe4, 0x74, 0x8c, 0x9f, 0x02,
+	0x9b, 0xa2, 0x32, 0xd9, 0x49, 0x96, 0x08, 0x83, 0x37, 0x79, 0xf4, 0xb6, 0xed, 0xda, 0xf6, 0x6b,
+	0xb8, 0xb3, 0x61, 0xeb, 0xf3, 0x9d, 0xc0, 0x20, 0xa9, 0x61, 0xeb, 0x63, 0xc8, 0x9b, 0x10, 0xd5,
+	0x2f, 0x29, 0xff, 0x24, 0xda, 0x89, 0x38, 0xfa, 0xad, 0x03, 0xf0, 0x65, 0xc5, 0x08, 0x7b, 0x0a,
+	0x83, 0xe9, 0x29, 0x26, 0x8b, 0xd7, 0x52, 0x2d, 0x50, 0xb1, 0x3b, 0x9b, 0x6c, 0xd9, 0x08, 0xc7,
+	0x57, 0x52, 0x18, 0xdf, 0x62, 0x53, 0x18, 0x5a, 0xdb, 0xaf, 0xa4, 0xa2, 0xc5, 0xca, 0xde, 0x6f,
+	0xea, 0x35, 0x3e, 0x8e, 0xe3, 0x68, 0xf7, 0xa2, 0x72, 0xf2, 0x0c, 0xfa, 0xd5, 0xc6, 0x64, 0xf7,
+	0x9b, 0x8a, 0xdb, 0x0b, 0x78, 0xfc, 0xe0, 0x9a, 0xdb, 0xca, 0x17, 0x87, 0x91, 0xeb, 0xe4, 0xba,
+	0x33, 0x36, 0x5d, 0x6e, 0x77, 0xfc, 0xf8, 0xe0, 0xba, 0xdb, 0xca, 0xe7, 0x17, 0xd0, 0xa1, 0xfa,
+	0x6f, 0x26, 0xd7, 0xe8, 0x88, 0xf1, 0xc3, 0x8d, 0xe6, 0xdf, 0xad, 0x51, 0x7c, 0x8b, 0xbd, 0xa2,
+	0xb8, 0x72, 0x3c, 0x6f, 0xdc, 0xb2, 0x83, 0x6b, 0xcd, 0xde, 0xd8, 0xed, 0x77, 0x65, 0xba, 0x8d,
+	0x4f, 0xef, 0xfe, 0x36, 0xd5, 0x0e, 0x1f, 0x1f, 0x5c, 0x8d, 0xd7, 0xde, 0x8e, 0xbb, 0xf6, 0xe7,
+	0xfc, 0xe3, 0x7f, 0x07, 0x00, 0x93, 0x15, 0xd1, 0xc8, 0xb0, 0x0b, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -263,6 +1317,11 @@ const _ = grpc.SupportPackageIsVersion4
 type DockeratorClient interface {
 	CheckWorker(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	CheckForTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*TaskResponse, error)
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	ReportNodeStatus(ctx context.Context, in *NodeStatusReport, opts ...grpc.CallOption) (*NodeStatusResponse, error)
+	Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (*CertificateResponse, error)
+	RenewCertificate(ctx context.Context, in *CertificateRequest, opts ...grpc.CallOption) (*CertificateResponse, error)
+	ReportTaskStatus(ctx context.Context, in *TaskStatus, opts ...grpc.CallOption) (*TaskStatusResponse, error)
 }
 
 type dockeratorClient struct {
@@ -291,10 +1350,60 @@ func (c *dockeratorClient) CheckForTask(ctx context.Context, in *TaskRequest, op
 	return out, nil
 }
 
+func (c *dockeratorClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, "/dockerator.Dockerator/Heartbeat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dockeratorClient) ReportNodeStatus(ctx context.Context, in *NodeStatusReport, opts ...grpc.CallOption) (*NodeStatusResponse, error) {
+	out := new(NodeStatusResponse)
+	err := c.cc.Invoke(ctx, "/dockerator.Dockerator/ReportNodeStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dockeratorClient) Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (*CertificateResponse, error) {
+	out := new(CertificateResponse)
+	err := c.cc.Invoke(ctx, "/dockerator.Dockerator/Join", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dockeratorClient) RenewCertificate(ctx context.Context, in *CertificateRequest, opts ...grpc.CallOption) (*CertificateResponse, error) {
+	out := new(CertificateResponse)
+	err := c.cc.Invoke(ctx, "/dockerator.Dockerator/RenewCertificate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dockeratorClient) ReportTaskStatus(ctx context.Context, in *TaskStatus, opts ...grpc.CallOption) (*TaskStatusResponse, error) {
+	out := new(TaskStatusResponse)
+	err := c.cc.Invoke(ctx, "/dockerator.Dockerator/ReportTaskStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DockeratorServer is the server API for Dockerator service.
 type DockeratorServer interface {
 	CheckWorker(context.Context, *Request) (*Response, error)
 	CheckForTask(context.Context, *TaskRequest) (*TaskResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	ReportNodeStatus(context.Context, *NodeStatusReport) (*NodeStatusResponse, error)
+	Join(context.Context, *JoinRequest) (*CertificateResponse, error)
+	RenewCertificate(context.Context, *CertificateRequest) (*CertificateResponse, error)
+	ReportTaskStatus(context.Context, *TaskStatus) (*TaskStatusResponse, error)
 }
 
 // UnimplementedDockeratorServer can be embedded to have forward compatible implementations.
@@ -307,6 +1416,21 @@ func (*UnimplementedDockeratorServer) CheckWorker(ctx context.Context, req *Requ
 func (*UnimplementedDockeratorServer) CheckForTask(ctx context.Context, req *TaskRequest) (*TaskResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckForTask not implemented")
 }
+func (*UnimplementedDockeratorServer) Heartbeat(ctx context.Context, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (*UnimplementedDockeratorServer) ReportNodeStatus(ctx context.Context, req *NodeStatusReport) (*NodeStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportNodeStatus not implemented")
+}
+func (*UnimplementedDockeratorServer) Join(ctx context.Context, req *JoinRequest) (*CertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Join not implemented")
+}
+func (*UnimplementedDockeratorServer) RenewCertificate(ctx context.Context, req *CertificateRequest) (*CertificateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewCertificate not implemented")
+}
+func (*UnimplementedDockeratorServer) ReportTaskStatus(ctx context.Context, req *TaskStatus) (*TaskStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportTaskStatus not implemented")
+}
 
 func RegisterDockeratorServer(s *grpc.Server, srv DockeratorServer) {
 	s.RegisterService(&_Dockerator_serviceDesc, srv)
@@ -348,6 +1472,96 @@ func _Dockerator_CheckForTask_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Dockerator_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DockeratorServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dockerator.Dockerator/Heartbeat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DockeratorServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dockerator_ReportNodeStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeStatusReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DockeratorServer).ReportNodeStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dockerator.Dockerator/ReportNodeStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DockeratorServer).ReportNodeStatus(ctx, req.(*NodeStatusReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dockerator_Join_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DockeratorServer).Join(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dockerator.Dockerator/Join",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DockeratorServer).Join(ctx, req.(*JoinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dockerator_RenewCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DockeratorServer).RenewCertificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dockerator.Dockerator/RenewCertificate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DockeratorServer).RenewCertificate(ctx, req.(*CertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dockerator_ReportTaskStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskStatus)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DockeratorServer).ReportTaskStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dockerator.Dockerator/ReportTaskStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DockeratorServer).ReportTaskStatus(ctx, req.(*TaskStatus))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Dockerator_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "dockerator.Dockerator",
 	HandlerType: (*DockeratorServer)(nil),
@@ -360,6 +1574,26 @@ var _Dockerator_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CheckForTask",
 			Handler:    _Dockerator_CheckForTask_Handler,
 		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _Dockerator_Heartbeat_Handler,
+		},
+		{
+			MethodName: "ReportNodeStatus",
+			Handler:    _Dockerator_ReportNodeStatus_Handler,
+		},
+		{
+			MethodName: "Join",
+			Handler:    _Dockerator_Join_Handler,
+		},
+		{
+			MethodName: "RenewCertificate",
+			Handler:    _Dockerator_RenewCertificate_Handler,
+		},
+		{
+			MethodName: "ReportTaskStatus",
+			Handler:    _Dockerator_ReportTaskStatus_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "dockerator.proto",
@@ -0,0 +1,239 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+const etcdTimeout = 5 * time.Second
+
+// EtcdStore - store shared by several servers through etcd v3 cluster
+type EtcdStore struct {
+	cli *clientv3.Client
+}
+
+// InitEtcd - connect to etcd cluster
+func InitEtcd(endpoints []string) (db *EtcdStore) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdTimeout,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &EtcdStore{cli}
+}
+
+// StartEmbeddedEtcd - run single member etcd server in-process, returns its client endpoint
+func StartEmbeddedEtcd(dir, clientURL, peerURL string) (endpoint string, stop func()) {
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cu, err := url.Parse(clientURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pu, err := url.Parse(peerURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = []url.URL{*cu}, []url.URL{*cu}
+	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = []url.URL{*pu}, []url.URL{*pu}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(time.Minute):
+		e.Server.Stop()
+		log.Fatal("embedded etcd took too long to start")
+	}
+	return clientURL, e.Close
+}
+
+// Client - underlying etcd client, used for leader election
+func (s *EtcdStore) Client() *clientv3.Client {
+	return s.cli
+}
+
+func (s *EtcdStore) Put(key, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	_, err := s.cli.Put(ctx, string(key), string(value))
+	return err
+}
+
+func (s *EtcdStore) Get(key []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	resp, err := s.cli.Get(ctx, string(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (s *EtcdStore) Delete(key []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	_, err := s.cli.Delete(ctx, string(key))
+	return err
+}
+
+func (s *EtcdStore) Has(key []byte) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	resp, err := s.cli.Get(ctx, string(key), clientv3.WithCountOnly())
+	if err != nil {
+		log.Printf("Error during checking key %v - %v", string(key), err)
+		return false
+	}
+	return resp.Count > 0
+}
+
+func (s *EtcdStore) Scan(prefix []byte, f func(key []byte) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	opts := []clientv3.OpOption{clientv3.WithKeysOnly(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend)}
+	if len(prefix) == 0 {
+		opts = append(opts, clientv3.WithFromKey())
+		prefix = []byte{0}
+	} else {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	resp, err := s.cli.Get(ctx, string(prefix), opts...)
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		if err := f(kv.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watch - events of keys with prefix until ctx is done, failed watch is opened again after last received revision
+func (s *EtcdStore) Watch(ctx context.Context, prefix string) <-chan Event {
+	events := make(chan Event, 100)
+	go func() {
+		defer close(events)
+		rev := int64(0)
+		for ctx.Err() == nil {
+			rev = s.watch(ctx, prefix, rev, events)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	return events
+}
+
+// watch - send events after revision rev until watch fails, returns revision of last sent event
+func (s *EtcdStore) watch(ctx context.Context, prefix string, rev int64, events chan<- Event) int64 {
+	wctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+	opts := []clientv3.OpOption{clientv3.WithPrefix()}
+	if rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev+1))
+	}
+	for resp := range s.cli.Watch(wctx, prefix, opts...) {
+		if err := resp.Err(); err != nil {
+			if resp.CompactRevision > 0 {
+				log.Printf("Watch of %q missed events compacted before revision %v", prefix, resp.CompactRevision)
+				rev = resp.CompactRevision - 1
+			}
+			log.Printf("Watch of %q failed, watching again: %v", prefix, err)
+			return rev
+		}
+		for _, ev := range resp.Events {
+			e := Event{"put", string(ev.Kv.Key), string(ev.Kv.Value)}
+			if ev.Type == clientv3.EventTypeDelete {
+				e.Op, e.Value = "delete", ""
+			}
+			select {
+			case events <- e:
+				rev = ev.Kv.ModRevision
+			case <-ctx.Done():
+				return rev
+			}
+		}
+	}
+	return rev
+}
+
+func (s *EtcdStore) Txn(cmps []Compare, ops []Op) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	ifs := []clientv3.Cmp{}
+	for _, c := range cmps {
+		if c.Missing {
+			ifs = append(ifs, clientv3.Compare(clientv3.CreateRevision(c.Key), "=", 0))
+		} else {
+			ifs = append(ifs, clientv3.Compare(clientv3.Value(c.Key), "=", c.Value))
+		}
+	}
+	thens := []clientv3.Op{}
+	for _, op := range ops {
+		switch op.Op {
+		case "put":
+			thens = append(thens, clientv3.OpPut(op.Key, op.Value))
+		case "delete":
+			thens = append(thens, clientv3.OpDelete(op.Key))
+		default:
+			return false, errors.New("unknown txn operation " + op.Op)
+		}
+	}
+	resp, err := s.cli.Txn(ctx).If(ifs...).Then(thens...).Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// grantLease - key lives while etcd lease holds, lease id is its value so any server can renew it with keepalive
+func (s *EtcdStore) grantLease(key string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	if v, err := s.Get([]byte(key)); err == nil {
+		if id, err := strconv.ParseInt(string(v), 16, 64); err == nil {
+			if _, err := s.cli.KeepAliveOnce(ctx, clientv3.LeaseID(id)); err == nil {
+				return nil
+			}
+		}
+	}
+	// lease expired or key was written by older version
+	lease, err := s.cli.Grant(ctx, int64(math.Ceil(ttl.Seconds())))
+	if err != nil {
+		return err
+	}
+	_, err = s.cli.Put(ctx, key, strconv.FormatInt(int64(lease.ID), 16), clientv3.WithLease(lease.ID))
+	return err
+}
+
+// leaseAlive - etcd removes key once its lease expires
+func (s *EtcdStore) leaseAlive(key string) bool {
+	v, err := s.Get([]byte(key))
+	if err != nil {
+		return false
+	}
+	_, err = strconv.ParseInt(string(v), 16, 64)
+	return err == nil
+}
+
+func (s *EtcdStore) Close() error {
+	return s.cli.Close()
+}
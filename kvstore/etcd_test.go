@@ -0,0 +1,195 @@
+package kvstore
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// freeURL - http url of unused local port
+func freeURL(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return "http://" + l.Addr().String()
+}
+
+// testEtcd - store backed by etcd server started in-process for the test
+func testEtcd(t *testing.T) *EtcdStore {
+	endpoint, stop := StartEmbeddedEtcd(t.TempDir(), freeURL(t), freeURL(t))
+	db := InitEtcd([]string{endpoint})
+	t.Cleanup(func() {
+		db.Close()
+		stop()
+	})
+	return db
+}
+
+func TestEtcdPutGetDelete(t *testing.T) {
+	db := testEtcd(t)
+	if err := db.Put([]byte("Spec-web"), []byte(`{"rs":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	v, err := db.Get([]byte("Spec-web"))
+	if err != nil || string(v) != `{"rs":2}` {
+		t.Fatalf("get = %q, %v", v, err)
+	}
+	if !db.Has([]byte("Spec-web")) {
+		t.Fatal("stored key is missing")
+	}
+	if err := db.Delete([]byte("Spec-web")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get([]byte("Spec-web")); err != ErrKeyNotFound {
+		t.Fatalf("get of deleted key: %v", err)
+	}
+	if db.Has([]byte("Spec-web")) {
+		t.Fatal("deleted key exists")
+	}
+}
+
+func TestEtcdScan(t *testing.T) {
+	db := testEtcd(t)
+	for _, k := range []string{"Task-b", "Task-a", "Tasks", "Spec-web"} {
+		db.Put([]byte(k), []byte("1"))
+	}
+	if got := KeysList(db, "Task-"); fmt.Sprint(got) != "[Task-a Task-b]" {
+		t.Fatalf("prefix scan = %v", got)
+	}
+	if got := KeysList(db, ""); fmt.Sprint(got) != "[Spec-web Task-a Task-b Tasks]" {
+		t.Fatalf("full scan = %v", got)
+	}
+}
+
+func TestEtcdWatch(t *testing.T) {
+	db := testEtcd(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := db.Watch(ctx, "Task-")
+	// watch is established asynchronously
+	time.Sleep(200 * time.Millisecond)
+	db.Put([]byte("Spec-web"), []byte("ignored"))
+	db.Put([]byte("Task-a"), []byte("create"))
+	db.Delete([]byte("Task-a"))
+	want := []Event{{"put", "Task-a", "create"}, {"delete", "Task-a", ""}}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e != w {
+				t.Fatalf("event = %+v, want %+v", e, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event %+v", w)
+		}
+	}
+	cancel()
+	for range events {
+	}
+}
+
+func TestEtcdWatchAfterCompaction(t *testing.T) {
+	db := testEtcd(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var last int64
+	for _, k := range []string{"Task-a", "Task-b", "Task-c"} {
+		resp, err := db.cli.Put(ctx, k, "create")
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = resp.Header.Revision
+	}
+	if _, err := db.cli.Compact(ctx, last); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Event, 10)
+	// watch resuming from compacted revision fails and continues from compaction
+	rev := db.watch(ctx, "Task-", 1, events)
+	if rev != last-1 {
+		t.Fatalf("revision after failed watch = %v, want %v", rev, last-1)
+	}
+	go db.watch(ctx, "Task-", rev, events)
+	select {
+	case e := <-events:
+		if e != (Event{"put", "Task-c", "create"}) {
+			t.Fatalf("event = %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after compaction")
+	}
+}
+
+func TestEtcdTxn(t *testing.T) {
+	db := testEtcd(t)
+	ok, err := db.Txn([]Compare{{Key: "Services", Missing: true}}, []Op{{"put", "Services", `["web"]`}, {"put", "Spec-web", "{}"}})
+	if err != nil || !ok {
+		t.Fatalf("txn on missing key = %v, %v", ok, err)
+	}
+	ok, err = db.Txn([]Compare{{Key: "Services", Missing: true}}, []Op{{"put", "Services", `["api"]`}})
+	if err != nil || ok {
+		t.Fatalf("txn with failed compare = %v, %v", ok, err)
+	}
+	ok, err = db.Txn([]Compare{{Key: "Services", Value: `["web"]`}}, []Op{{"put", "Services", "[]"}, {"delete", "Spec-web", ""}})
+	if err != nil || !ok {
+		t.Fatalf("txn on value = %v, %v", ok, err)
+	}
+	if v, _ := GetKV(db, "Services"); v != "[]" || db.Has([]byte("Spec-web")) {
+		t.Fatalf("txn ops not applied: %q", v)
+	}
+}
+
+func TestEtcdConcurrentAppend(t *testing.T) {
+	db := testEtcd(t)
+	wg := sync.WaitGroup{}
+	want := []string{}
+	for i := 0; i < 10; i++ {
+		value := fmt.Sprintf("web-%02d", i)
+		want = append(want, value)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			AppendKV(db, "Services", value)
+		}()
+	}
+	wg.Wait()
+	got := GetList(db, "Services")
+	sort.Strings(got)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("lost appends: %v", got)
+	}
+	EjectKV(db, "Services", "web-03")
+	if InList(db, "Services", "web-03") || len(GetList(db, "Services")) != 9 {
+		t.Fatal("eject failed")
+	}
+}
+
+func TestEtcdLease(t *testing.T) {
+	db := testEtcd(t)
+	if err := GrantLease(db, "Lease-n1", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := GetKV(db, "Lease-n1")
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Second)
+		if err := GrantLease(db, "Lease-n1", 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if !LeaseAlive(db, "Lease-n1") {
+			t.Fatal("renewed lease expired")
+		}
+	}
+	// renewal is keepalive of the same lease, not a new write
+	if v, _ := GetKV(db, "Lease-n1"); v != id {
+		t.Fatalf("lease was granted again: %v, was %v", v, id)
+	}
+	time.Sleep(4 * time.Second)
+	if LeaseAlive(db, "Lease-n1") {
+		t.Fatal("lease is alive after ttl without renewal")
+	}
+}
@@ -1,15 +1,322 @@
 package kvstore
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"strings"
+	"time"
 
 	"git.mills.io/prologic/bitcask"
 )
 
+// Task - scheduled container operation
+type Task struct {
+	ID        string      `json:"id"`
+	Job       string      `json:"job"`
+	Container string      `json:"container"`
+	Old       string      `json:"old,omitempty"`
+	Service   string      `json:"service"`
+	Image     string      `json:"image"`
+	Replicas  int         `json:"rs"`
+	CPUs      float64     `json:"cpus,omitempty"`
+	Memory    int64       `json:"memory,omitempty"`
+	Secrets   []SecretRef `json:"secrets,omitempty"`
+	Configs   []ConfigRef `json:"configs,omitempty"`
+	Registry  string      `json:"registry,omitempty"`
+	Pull      string      `json:"pull_policy,omitempty"`
+}
+
+// ConfigRef - config of service namespace mounted into container at path, version is the one delivered
+type ConfigRef struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Version int    `json:"version,omitempty"`
+}
+
+// SecretRef - secret of service namespace delivered to container as file or env var
+type SecretRef struct {
+	Name string `json:"name"`
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// Params - task params in agent format
+func (t Task) Params() string {
+	ns, service := SplitName(t.Service)
+	if t.Job == "recreate" {
+		return fmt.Sprintf("%v %v %v %v %v %v %v %v", t.Old, t.Container, t.Image, service, t.ID, ns, t.CPUs, t.Memory)
+	}
+	return fmt.Sprintf("%v %v %v %v %v %v %v %v", t.Container, t.Image, t.Replicas, service, t.ID, ns, t.CPUs, t.Memory)
+}
+
+// DefaultNamespace - namespace of services with unqualified names
+const DefaultNamespace = "default"
+
+// QualifiedName - "<namespace>.<service>" store name of service, default namespace keeps plain names
+func QualifiedName(namespace, service string) string {
+	if namespace == DefaultNamespace || namespace == "" {
+		return service
+	}
+	return namespace + "." + service
+}
+
+// SplitName - namespace and service of qualified name
+func SplitName(qualified string) (namespace, service string) {
+	if i := strings.Index(qualified, "."); i >= 0 {
+		return qualified[:i], qualified[i+1:]
+	}
+	return DefaultNamespace, qualified
+}
+
+// ServiceName - strip "-<xid>" suffix from container name
+func ServiceName(contName string) string {
+	if len(contName) < 21 {
+		return contName
+	}
+	return contName[:len(contName)-21]
+}
+
+// NodeKey - list of containers deployed to node, prefixed apart from system keys
+func NodeKey(node string) string {
+	return "Node-" + node
+}
+
+// ServiceKey - list of containers of qualified service
+func ServiceKey(service string) string {
+	return "Service-" + service
+}
+
+// Container - stored record of deployed container
+type Container struct {
+	Image    string      `json:"image"`
+	Replicas int         `json:"rs"`
+	CPUs     float64     `json:"cpus,omitempty"`
+	Memory   int64       `json:"memory,omitempty"`
+	Secrets  []SecretRef `json:"secrets,omitempty"`
+	Configs  []ConfigRef `json:"configs,omitempty"`
+	Registry string      `json:"registry,omitempty"`
+	Pull     string      `json:"pull_policy,omitempty"`
+}
+
+// Replicator - applies write operation through cluster consensus
+type Replicator func(op, key, value string) error
+
+var replicator Replicator
+
+// SetReplicator - route all writes through replicator instead of local db
+func SetReplicator(r Replicator) {
+	replicator = r
+}
+
+type txn struct {
+	Cmps []Compare `json:"cmps"`
+	Ops  []Op      `json:"ops"`
+}
+
+// ApplyLocal - apply write operation to local db
+func ApplyLocal(db Store, op, key, value string) error {
+	switch op {
+	case "put":
+		return db.Put([]byte(key), []byte(value))
+	case "delete":
+		return db.Delete([]byte(key))
+	case "txn":
+		t := txn{}
+		if err := json.Unmarshal([]byte(value), &t); err != nil {
+			return err
+		}
+		ok, err := db.Txn(t.Cmps, t.Ops)
+		if err == nil && !ok {
+			err = ErrTxnFailed
+		}
+		return err
+	}
+	return fmt.Errorf("unknown operation %v", op)
+}
+
+func write(db Store, op, key, value string) error {
+	if replicator != nil {
+		return replicator(op, key, value)
+	}
+	return ApplyLocal(db, op, key, value)
+}
+
+// Txn - apply ops atomically if all compares hold
+func Txn(db Store, cmps []Compare, ops []Op) (succeeded bool, err error) {
+	if replicator == nil {
+		return db.Txn(cmps, ops)
+	}
+	t, err := json.Marshal(txn{cmps, ops})
+	if err != nil {
+		return false, err
+	}
+	err = replicator("txn", "", string(t))
+	if err == ErrTxnFailed {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// updateRetries - attempts of Update before giving up on concurrent writers
+const updateRetries = 10
+
+// Batch - reads and writes of optimistic transaction, every key read is compared on commit
+type Batch struct {
+	db     Store
+	reads  map[string]Compare
+	writes map[string]Op
+	order  []string
+	// first failed read, batch is not committed since its compares are incomplete
+	err error
+}
+
+// Update - run f and commit its writes atomically if keys it read did not change meanwhile, f is run again on conflict
+func Update(db Store, f func(b *Batch) error) error {
+	for i := 0; i < updateRetries; i++ {
+		b := &Batch{db: db, reads: map[string]Compare{}, writes: map[string]Op{}}
+		if err := f(b); err != nil {
+			return err
+		}
+		if b.err != nil {
+			return b.err
+		}
+		if len(b.order) == 0 {
+			return nil
+		}
+		cmps := []Compare{}
+		for _, c := range b.reads {
+			cmps = append(cmps, c)
+		}
+		ops := []Op{}
+		for _, k := range b.order {
+			ops = append(ops, b.writes[k])
+		}
+		ok, err := Txn(db, cmps, ops)
+		if err != nil || ok {
+			return err
+		}
+		// spread writers racing for the same keys
+		time.Sleep(time.Duration(rand.Int63n(int64(i+1) * int64(5*time.Millisecond))))
+	}
+	return ErrTxnFailed
+}
+
+// Get - value written by batch or stored one, which is then compared on commit
+func (b *Batch) Get(key string) (string, error) {
+	if op, ok := b.writes[key]; ok {
+		if op.Op == "delete" {
+			return "", ErrKeyNotFound
+		}
+		return op.Value, nil
+	}
+	if c, ok := b.reads[key]; ok {
+		if c.Missing {
+			return "", ErrKeyNotFound
+		}
+		return c.Value, nil
+	}
+	v, err := b.db.Get([]byte(key))
+	if err == ErrKeyNotFound {
+		b.reads[key] = Compare{Key: key, Missing: true}
+		return "", err
+	}
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return "", err
+	}
+	b.reads[key] = Compare{Key: key, Value: string(v)}
+	return string(v), nil
+}
+
+// Has - check if key exists
+func (b *Batch) Has(key string) bool {
+	_, err := b.Get(key)
+	return err == nil
+}
+
+// GetJSON - decode json value by key
+func (b *Batch) GetJSON(key string, v interface{}) error {
+	value, err := b.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(value), v)
+}
+
+// GetList - return list stored by key
+func (b *Batch) GetList(key string) (values []string) {
+	values = []string{}
+	if !b.Has(key) {
+		return
+	}
+	if err := b.GetJSON(key, &values); err != nil {
+		log.Printf("Error during decoding list %v - %v", key, err)
+	}
+	return
+}
+
+// InList - check if list by key contains value
+func (b *Batch) InList(key, value string) bool {
+	for _, v := range b.GetList(key) {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Batch) write(op Op) {
+	if _, ok := b.writes[op.Key]; !ok {
+		b.order = append(b.order, op.Key)
+	}
+	b.writes[op.Key] = op
+}
+
+// Put - write key on commit
+func (b *Batch) Put(key, value string) {
+	b.write(Op{"put", key, value})
+}
+
+// PutJSON - write value encoded to json on commit
+func (b *Batch) PutJSON(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b.Put(key, string(value))
+	return nil
+}
+
+// Delete - delete key on commit
+func (b *Batch) Delete(key string) {
+	b.write(Op{"delete", key, ""})
+}
+
+// Append - append value to list if it is not there yet
+func (b *Batch) Append(key, value string) {
+	if b.InList(key, value) {
+		return
+	}
+	b.PutJSON(key, append(b.GetList(key), value))
+}
+
+// Eject - eject one of values from list by key
+func (b *Batch) Eject(key, value string) {
+	restValues := []string{}
+	for _, v := range b.GetList(key) {
+		if v != value {
+			restValues = append(restValues, v)
+		}
+	}
+	b.PutJSON(key, restValues)
+}
+
 // CountRS - count replicas for service
-func CountRS(db *bitcask.Bitcask, name string) (rs int) {
+func CountRS(db Store, name string) (rs int) {
 	rs = 0
 	rsInc := func(key []byte) error {
 		rs++
@@ -21,36 +328,46 @@ func CountRS(db *bitcask.Bitcask, name string) (rs int) {
 }
 
 // TasksList - return list of tasks
-func TasksList(db *bitcask.Bitcask) (tasks []string) {
+func TasksList(db Store) (tasks []string) {
 	tasks = []string{}
 	appendTask := func(key []byte) error {
 		tasks = append(tasks, string(key))
 		return nil
 	}
-	db.Scan([]byte("Task"), appendTask)
+	db.Scan([]byte("Task-"), appendTask)
+	return
+}
+
+// KeysList - return keys by prefix
+func KeysList(db Store, prefix string) (keys []string) {
+	keys = []string{}
+	db.Scan([]byte(prefix), func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
 	return
 }
 
 // KeyExist - check if key exist
-func KeyExist(db *bitcask.Bitcask, key string) bool {
+func KeyExist(db Store, key string) bool {
 	return db.Has([]byte(key))
 }
 
 // InitDB - create db instance
-func InitDB(path string) (db *bitcask.Bitcask) {
+func InitDB(path string) (db Store) {
 	opts := []bitcask.Option{
 		bitcask.WithSync(true),
 	}
-	db, err := bitcask.Open(path, opts...)
+	b, err := bitcask.Open(path, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return
+	return &bitcaskStore{db: b, watchers: map[*watcher]bool{}}
 }
 
 // PutKV - put k/v to config db
-func PutKV(db *bitcask.Bitcask, key, value string) (err error) {
-	err = db.Put([]byte(key), []byte(value))
+func PutKV(db Store, key, value string) (err error) {
+	err = write(db, "put", key, value)
 	if err != nil {
 		log.Printf("Error during inserting KV - %v", err)
 	}
@@ -58,8 +375,8 @@ func PutKV(db *bitcask.Bitcask, key, value string) (err error) {
 }
 
 // DeleteKV - delete key from config db
-func DeleteKV(db *bitcask.Bitcask, key string) (result bool) {
-	err := db.Delete([]byte(key))
+func DeleteKV(db Store, key string) (result bool) {
+	err := write(db, "delete", key, "")
 	if err != nil {
 		log.Printf("Error during deleting KV - %v", err)
 		result = false
@@ -70,36 +387,70 @@ func DeleteKV(db *bitcask.Bitcask, key string) (result bool) {
 }
 
 // GetKV - put k/v to config db
-func GetKV(db *bitcask.Bitcask, key string) (value string, err error) {
+func GetKV(db Store, key string) (value string, err error) {
 	val, err := db.Get([]byte(key))
 	value = string(val)
 	return
 }
 
-// AppendKV - append value if key exist or create if not
-func AppendKV(db *bitcask.Bitcask, key, value string) {
-	if KeyExist(db, key) {
-		v, _ := GetKV(db, key)
-		if strings.Contains(v, value) != true {
-			values := strings.Split(v, " ")
-			value = strings.Join(append(values, value), " ")
-			PutKV(db, key, value)
-		}
-	} else {
-		PutKV(db, key, value)
+// PutJSON - put value encoded to json
+func PutJSON(db Store, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
+	return PutKV(db, key, string(value))
 }
 
-// EjectKV - exect on of values by key
-func EjectKV(db *bitcask.Bitcask, key, value string) {
-	val, _ := GetKV(db, key)
-	values := strings.Split(val, " ")
-	restValues := []string{}
-	for _, v := range values {
-		if v != value {
-			restValues = append(restValues, v)
+// GetJSON - decode json value by key
+func GetJSON(db Store, key string, v interface{}) error {
+	value, err := db.Get([]byte(key))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, v)
+}
+
+// GetList - return list stored by key
+func GetList(db Store, key string) (values []string) {
+	values = []string{}
+	if !KeyExist(db, key) {
+		return
+	}
+	if err := GetJSON(db, key, &values); err != nil {
+		log.Printf("Error during decoding list %v - %v", key, err)
+	}
+	return
+}
+
+// InList - check if list by key contains value
+func InList(db Store, key, value string) bool {
+	for _, v := range GetList(db, key) {
+		if v == value {
+			return true
 		}
 	}
-	resultValues := strings.Join(restValues, " ")
-	PutKV(db, key, resultValues)
+	return false
+}
+
+// AppendKV - append value to list if key exist or create if not, concurrent changes of list are retried
+func AppendKV(db Store, key, value string) {
+	err := Update(db, func(b *Batch) error {
+		b.Append(key, value)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error during appending %v to %v - %v", value, key, err)
+	}
+}
+
+// EjectKV - eject one of values from list by key, concurrent changes of list are retried
+func EjectKV(db Store, key, value string) {
+	err := Update(db, func(b *Batch) error {
+		b.Eject(key, value)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error during ejecting %v from %v - %v", value, key, err)
+	}
 }
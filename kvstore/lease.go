@@ -0,0 +1,38 @@
+package kvstore
+
+import (
+	"time"
+)
+
+// Lease - liveness record which expires unless renewed before ttl
+type Lease struct {
+	Expires int64 `json:"expires"`
+	TTL     int64 `json:"ttl"`
+}
+
+// leaser - store expiring keys natively, renewal does not write key again
+type leaser interface {
+	grantLease(key string, ttl time.Duration) error
+	leaseAlive(key string) bool
+}
+
+// GrantLease - create or renew lease by key
+func GrantLease(db Store, key string, ttl time.Duration) error {
+	if l, ok := db.(leaser); ok {
+		return l.grantLease(key, ttl)
+	}
+	lease := Lease{time.Now().Add(ttl).Unix(), int64(ttl.Seconds())}
+	return PutJSON(db, key, lease)
+}
+
+// LeaseAlive - check if lease by key exists and not expired
+func LeaseAlive(db Store, key string) bool {
+	if l, ok := db.(leaser); ok {
+		return l.leaseAlive(key)
+	}
+	lease := Lease{}
+	if err := GetJSON(db, key, &lease); err != nil {
+		return false
+	}
+	return time.Now().Unix() < lease.Expires
+}
@@ -0,0 +1,301 @@
+package kvstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const schemaKey = "SchemaVersion"
+
+// Migration - upgrade of stored records to the next schema version, db reads through changes of earlier migrations
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db Store, tx *MigrationTx) error
+}
+
+// MigrationTx - collects changes of all pending migrations, they are committed together unless dry run
+type MigrationTx struct {
+	db      Store
+	writes  map[string]Op
+	order   []string
+	Changes []string
+}
+
+func (tx *MigrationTx) write(op Op) {
+	if _, ok := tx.writes[op.Key]; !ok {
+		tx.order = append(tx.order, op.Key)
+	}
+	tx.writes[op.Key] = op
+}
+
+// Put - write key in new format
+func (tx *MigrationTx) Put(key, value string) error {
+	tx.Changes = append(tx.Changes, fmt.Sprintf("put %v = %v", key, value))
+	tx.write(Op{"put", key, value})
+	return nil
+}
+
+// PutJSON - write key encoded to json
+func (tx *MigrationTx) PutJSON(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Put(key, string(value))
+}
+
+// Delete - remove key of old format
+func (tx *MigrationTx) Delete(key string) error {
+	tx.Changes = append(tx.Changes, fmt.Sprintf("delete %v", key))
+	tx.write(Op{"delete", key, ""})
+	return nil
+}
+
+// overlay - store as it will be after commit of tx
+type overlay struct {
+	tx *MigrationTx
+}
+
+func (o overlay) Get(key []byte) ([]byte, error) {
+	if op, ok := o.tx.writes[string(key)]; ok {
+		if op.Op == "delete" {
+			return nil, ErrKeyNotFound
+		}
+		return []byte(op.Value), nil
+	}
+	return o.tx.db.Get(key)
+}
+
+func (o overlay) Has(key []byte) bool {
+	if op, ok := o.tx.writes[string(key)]; ok {
+		return op.Op == "put"
+	}
+	return o.tx.db.Has(key)
+}
+
+func (o overlay) Scan(prefix []byte, f func(key []byte) error) error {
+	keys := map[string]bool{}
+	err := o.tx.db.Scan(prefix, func(key []byte) error {
+		keys[string(key)] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, op := range o.tx.writes {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys[k] = op.Op == "put"
+		}
+	}
+	sorted := []string{}
+	for k, exists := range keys {
+		if exists {
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		if err := f([]byte(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o overlay) Put(key, value []byte) error {
+	return o.tx.Put(string(key), string(value))
+}
+
+func (o overlay) Delete(key []byte) error {
+	return o.tx.Delete(string(key))
+}
+
+func (o overlay) Watch(ctx context.Context, prefix string) <-chan Event {
+	return o.tx.db.Watch(ctx, prefix)
+}
+
+func (o overlay) Txn(cmps []Compare, ops []Op) (bool, error) {
+	return false, errors.New("txn is not supported in migration")
+}
+
+func (o overlay) Close() error {
+	return nil
+}
+
+var migrations = []Migration{
+	{1, "store lists, tasks and container records as json", jsonRecords},
+	{2, "move container lists of nodes and services to prefixed keys", prefixedLists},
+}
+
+// LatestVersion - schema version of current code
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion - schema version of stored data, 0 for data written before versioning
+func SchemaVersion(db Store) int {
+	v, err := GetKV(db, schemaKey)
+	if err != nil {
+		return 0
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Wrong schema version %q - %v", v, err)
+		return 0
+	}
+	return version
+}
+
+// Migrate - upgrade stored data to latest schema in one txn, dry run only reports changes
+func Migrate(db Store, dryRun bool) (changes []string, err error) {
+	stored, missing := GetKV(db, schemaKey)
+	current := SchemaVersion(db)
+	tx := &MigrationTx{db: db, writes: map[string]Op{}}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		log.Printf("Migrating schema to v%v: %v", m.Version, m.Description)
+		if err = m.Up(overlay{tx}, tx); err != nil {
+			return tx.Changes, fmt.Errorf("migration to v%v failed: %v", m.Version, err)
+		}
+		if err = tx.Put(schemaKey, strconv.Itoa(m.Version)); err != nil {
+			return tx.Changes, err
+		}
+	}
+	if dryRun || len(tx.order) == 0 {
+		return tx.Changes, nil
+	}
+	ops := []Op{}
+	for _, k := range tx.order {
+		ops = append(ops, tx.writes[k])
+	}
+	// schema version compare keeps concurrent migrations from applying twice
+	ok, err := Txn(db, []Compare{{Key: schemaKey, Value: stored, Missing: missing != nil}}, ops)
+	if err == nil && !ok {
+		err = errors.New("schema version changed during migration")
+	}
+	return tx.Changes, err
+}
+
+// oldList - split space-joined list of schema v0
+func oldList(db Store, key string) (values []string) {
+	v, _ := GetKV(db, key)
+	for _, value := range strings.Split(v, " ") {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return
+}
+
+func isJSON(db Store, key string) bool {
+	v, _ := GetKV(db, key)
+	return strings.HasPrefix(v, "[") || strings.HasPrefix(v, "{")
+}
+
+// jsonRecords - v1 replaces space-joined strings with json lists and objects
+func jsonRecords(db Store, tx *MigrationTx) error {
+	lists := map[string][]string{}
+	records := map[string]Container{}
+	tasks := map[string]Task{}
+
+	for _, key := range []string{"Nodes", "Services"} {
+		if KeyExist(db, key) && !isJSON(db, key) {
+			lists[key] = oldList(db, key)
+		}
+	}
+	for _, n := range oldList(db, "Nodes") {
+		if KeyExist(db, n) && !isJSON(db, n) {
+			lists[n] = oldList(db, n)
+		}
+	}
+	for _, s := range oldList(db, "Services") {
+		if !KeyExist(db, s) || isJSON(db, s) {
+			continue
+		}
+		lists[s] = oldList(db, s)
+		for _, c := range lists[s] {
+			if !KeyExist(db, c) || isJSON(db, c) {
+				continue
+			}
+			// "image replicas"
+			r := oldList(db, c)
+			rec := Container{}
+			if len(r) > 0 {
+				rec.Image = r[0]
+			}
+			if len(r) > 1 {
+				rec.Replicas, _ = strconv.Atoi(r[1])
+			}
+			records[c] = rec
+		}
+	}
+	for _, t := range TasksList(db) {
+		if isJSON(db, t) {
+			continue
+		}
+		// "job container image replicas"
+		r := oldList(db, t)
+		if len(r) < 3 {
+			return fmt.Errorf("unknown task format of %v", t)
+		}
+		task := Task{ID: t, Job: r[0], Container: r[1], Service: ServiceName(r[1]), Image: r[2], Replicas: 1}
+		if len(r) > 3 {
+			task.Replicas, _ = strconv.Atoi(r[3])
+		}
+		tasks[t] = task
+	}
+
+	for k, v := range lists {
+		if err := tx.PutJSON(k, v); err != nil {
+			return err
+		}
+	}
+	for k, v := range records {
+		if err := tx.PutJSON(k, v); err != nil {
+			return err
+		}
+	}
+	for k, v := range tasks {
+		if err := tx.PutJSON(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixedLists - v2 keeps container lists under "Node-<name>" and "Service-<name>",
+// so node and service names can't overwrite system keys like "Nodes" or "CA"
+func prefixedLists(db Store, tx *MigrationTx) error {
+	moves := map[string]string{}
+	for _, n := range GetList(db, "Nodes") {
+		moves[n] = NodeKey(n)
+	}
+	for _, s := range GetList(db, "Services") {
+		moves[s] = ServiceKey(s)
+	}
+	for old, key := range moves {
+		if !KeyExist(db, old) {
+			continue
+		}
+		values := GetList(db, old)
+		if KeyExist(db, key) {
+			values = append(GetList(db, key), values...)
+		}
+		if err := tx.PutJSON(key, values); err != nil {
+			return err
+		}
+		if err := tx.Delete(old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
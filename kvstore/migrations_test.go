@@ -0,0 +1,109 @@
+package kvstore
+
+import (
+	"fmt"
+	"testing"
+)
+
+// testDB - local store in temporary dir
+func testDB(t *testing.T) Store {
+	db := InitDB(t.TempDir())
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// v0State - records written before schema versions, lists are space joined
+func v0State(t *testing.T, db Store) {
+	for k, v := range map[string]string{
+		"Nodes":                     "n1 n2",
+		"n1":                        "web-cb0j2nq7f0s8aqq0ab10",
+		"Services":                  "web",
+		"web":                       "web-cb0j2nq7f0s8aqq0ab10",
+		"web-cb0j2nq7f0s8aqq0ab10":  "nginx:alpine 1",
+		"Task-cb0j2nq7f0s8aqq0ab20": "create web-cb0j2nq7f0s8aqq0ab30 nginx:alpine 2",
+	} {
+		if err := db.Put([]byte(k), []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	db := testDB(t)
+	v0State(t, db)
+	changes, err := Migrate(db, true)
+	if err != nil || len(changes) == 0 {
+		t.Fatalf("dry run = %v, %v", changes, err)
+	}
+	if SchemaVersion(db) != 0 {
+		t.Fatalf("dry run stored version %v", SchemaVersion(db))
+	}
+	if v, _ := GetKV(db, "Nodes"); v != "n1 n2" {
+		t.Fatalf("dry run changed Nodes to %q", v)
+	}
+	// v2 sees lists converted by v1
+	reported := map[string]bool{}
+	for _, c := range changes {
+		reported[c] = true
+	}
+	for _, c := range []string{
+		`put Node-n1 = ["web-cb0j2nq7f0s8aqq0ab10"]`,
+		`put Service-web = ["web-cb0j2nq7f0s8aqq0ab10"]`,
+		"delete n1",
+		"delete web",
+		"put SchemaVersion = 2",
+	} {
+		if !reported[c] {
+			t.Errorf("dry run did not report %q in %q", c, changes)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := testDB(t)
+	v0State(t, db)
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatal(err)
+	}
+	if SchemaVersion(db) != LatestVersion() {
+		t.Fatalf("version = %v, want %v", SchemaVersion(db), LatestVersion())
+	}
+	if got := GetList(db, "Nodes"); fmt.Sprint(got) != "[n1 n2]" {
+		t.Fatalf("nodes = %v", got)
+	}
+	if got := GetList(db, NodeKey("n1")); fmt.Sprint(got) != "[web-cb0j2nq7f0s8aqq0ab10]" {
+		t.Fatalf("containers of n1 = %v", got)
+	}
+	if got := GetList(db, ServiceKey("web")); fmt.Sprint(got) != "[web-cb0j2nq7f0s8aqq0ab10]" {
+		t.Fatalf("containers of web = %v", got)
+	}
+	if KeyExist(db, "n1") || KeyExist(db, "web") {
+		t.Fatal("unprefixed lists are left")
+	}
+	rec := Container{}
+	if err := GetJSON(db, "web-cb0j2nq7f0s8aqq0ab10", &rec); err != nil || rec.Image != "nginx:alpine" || rec.Replicas != 1 {
+		t.Fatalf("record = %+v, %v", rec, err)
+	}
+	task := Task{}
+	if err := GetJSON(db, "Task-cb0j2nq7f0s8aqq0ab20", &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Job != "create" || task.Container != "web-cb0j2nq7f0s8aqq0ab30" || task.Service != "web" || task.Replicas != 2 {
+		t.Fatalf("task = %+v", task)
+	}
+	// migrated store is left as is
+	if changes, err := Migrate(db, false); err != nil || len(changes) != 0 {
+		t.Fatalf("second migration = %v, %v", changes, err)
+	}
+}
+
+func TestMigrateUnknownTask(t *testing.T) {
+	db := testDB(t)
+	db.Put([]byte("Task-cb0j2nq7f0s8aqq0ab20"), []byte("create"))
+	if _, err := Migrate(db, false); err == nil {
+		t.Fatal("task of unknown format migrated")
+	}
+	if SchemaVersion(db) != 0 {
+		t.Fatal("failed migration stored version")
+	}
+}
@@ -0,0 +1,189 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"git.mills.io/prologic/bitcask"
+)
+
+// ErrKeyNotFound - requested key is absent in store
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrTxnFailed - txn compares did not hold, ops were not applied
+var ErrTxnFailed = errors.New("txn compare failed")
+
+// Store - backend of config db
+type Store interface {
+	Put(key, value []byte) error
+	Get(key []byte) ([]byte, error)
+	Delete(key []byte) error
+	Has(key []byte) bool
+	// Scan - call f for keys with prefix in ascending order
+	Scan(prefix []byte, f func(key []byte) error) error
+	Watch(ctx context.Context, prefix string) <-chan Event
+	Txn(cmps []Compare, ops []Op) (bool, error)
+	Close() error
+}
+
+// Event - change of watched key
+type Event struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Op - write operation, "put" or "delete"
+type Op struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Compare - txn condition, key must hold Value or be absent when Missing
+type Compare struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Missing bool   `json:"missing"`
+}
+
+type watcher struct {
+	prefix string
+	ch     chan Event
+}
+
+// bitcaskStore - embedded single host store
+type bitcaskStore struct {
+	db       *bitcask.Bitcask
+	mu       sync.Mutex
+	watchers map[*watcher]bool
+}
+
+func (s *bitcaskStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(key, value)
+}
+
+func (s *bitcaskStore) put(key, value []byte) error {
+	if err := s.db.Put(key, value); err != nil {
+		return err
+	}
+	s.notify(Event{"put", string(key), string(value)})
+	return nil
+}
+
+func (s *bitcaskStore) Get(key []byte) ([]byte, error) {
+	v, err := s.db.Get(key)
+	if err == bitcask.ErrKeyNotFound {
+		return nil, ErrKeyNotFound
+	}
+	return v, err
+}
+
+func (s *bitcaskStore) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delete(key)
+}
+
+func (s *bitcaskStore) delete(key []byte) error {
+	if err := s.db.Delete(key); err != nil {
+		return err
+	}
+	s.notify(Event{"delete", string(key), ""})
+	return nil
+}
+
+func (s *bitcaskStore) Has(key []byte) bool {
+	return s.db.Has(key)
+}
+
+// Scan - bitcask keeps keys unordered, they are collected and sorted before f is called
+func (s *bitcaskStore) Scan(prefix []byte, f func(key []byte) error) error {
+	keys := []string{}
+	collect := func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	}
+	var err error
+	if len(prefix) == 0 {
+		err = s.db.Fold(collect)
+	} else {
+		err = s.db.Scan(prefix, collect)
+	}
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := f([]byte(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *bitcaskStore) Watch(ctx context.Context, prefix string) <-chan Event {
+	w := &watcher{prefix, make(chan Event, 100)}
+	s.mu.Lock()
+	s.watchers[w] = true
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.watchers, w)
+		close(w.ch)
+		s.mu.Unlock()
+	}()
+	return w.ch
+}
+
+// notify - caller holds mu
+func (s *bitcaskStore) notify(e Event) {
+	for w := range s.watchers {
+		if !strings.HasPrefix(e.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- e:
+		default:
+			log.Printf("Watcher of %q is full, dropping event for %v", w.prefix, e.Key)
+		}
+	}
+}
+
+func (s *bitcaskStore) Txn(cmps []Compare, ops []Op) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range cmps {
+		v, err := s.db.Get([]byte(c.Key))
+		exists := err == nil
+		if c.Missing == exists || (exists && string(v) != c.Value) {
+			return false, nil
+		}
+	}
+	for _, op := range ops {
+		var err error
+		switch op.Op {
+		case "put":
+			err = s.put([]byte(op.Key), []byte(op.Value))
+		case "delete":
+			err = s.delete([]byte(op.Key))
+		default:
+			err = errors.New("unknown txn operation " + op.Op)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *bitcaskStore) Close() error {
+	return s.db.Close()
+}
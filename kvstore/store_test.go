@@ -0,0 +1,19 @@
+package kvstore
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScanSorted(t *testing.T) {
+	db := testDB(t)
+	for _, k := range []string{"Task-c", "Spec-web", "Task-a", "Tasks", "Task-b"} {
+		db.Put([]byte(k), []byte("1"))
+	}
+	if got := KeysList(db, "Task-"); fmt.Sprint(got) != "[Task-a Task-b Task-c]" {
+		t.Fatalf("prefix scan = %v", got)
+	}
+	if got := KeysList(db, ""); fmt.Sprint(got) != "[Spec-web Task-a Task-b Task-c Tasks]" {
+		t.Fatalf("full scan = %v", got)
+	}
+}
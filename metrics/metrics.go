@@ -1,81 +1,258 @@
 package metrics
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"log"
-	"os"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-	"io/ioutil"
-	"time"
-	"fmt"
+	"sync"
+)
+
+// Labels - label names and values of one series
+type Labels map[string]string
 
-	"github.com/labstack/echo"
+// Metric types of families
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
 )
-// Start metric handler endpoint
-func Start(queue chan string) {
-	go workerWriter(queue)
-	e := echo.New()
-	e.GET("/metrics", func(c echo.Context) error {
-		return c.File("/metrics")
-	})
-	e.Logger.Fatal(e.Start(":1221"))
-}
-
-func workerWriter(queue chan string) {
-	fmt.Println("worker started")
-	for {
-		for i := len(queue); i > 0; i = len(queue) {
-			metric := <-queue
-			writeMetric(metric)
+
+var (
+	validMetric = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	validLabel  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+type series struct {
+	labels string
+	value  float64
+}
+
+type family struct {
+	kind   string
+	help   string
+	series map[string]*series
+}
+
+// Registry - gauges and counters kept in memory, rendered on scrape
+type Registry struct {
+	sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry - empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Default - registry of package level functions
+var Default = NewRegistry()
+
+// Help - description of metric shown on scrape
+func (r *Registry) Help(name, text string) {
+	r.Lock()
+	defer r.Unlock()
+	if f := r.family(name, ""); f != nil {
+		f.help = text
+	}
+}
+
+// SetGauge - set current value of gauge series
+func (r *Registry) SetGauge(name string, labels Labels, value float64) {
+	r.Lock()
+	defer r.Unlock()
+	if s := r.series(name, Gauge, labels); s != nil {
+		s.value = value
+	}
+}
+
+// AddCounter - increase counter series, counters never go down
+func (r *Registry) AddCounter(name string, labels Labels, delta float64) {
+	if delta < 0 {
+		log.Printf("Counter %v can't decrease by %v", name, delta)
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	if s := r.series(name, Counter, labels); s != nil {
+		s.value += delta
+	}
+}
+
+// SetCounter - set counter series to total counted elsewhere, e.g. bytes reported by Docker
+func (r *Registry) SetCounter(name string, labels Labels, value float64) {
+	r.Lock()
+	defer r.Unlock()
+	if s := r.series(name, Counter, labels); s != nil {
+		s.value = value
+	}
+}
+
+// Delete - drop series, e.g. of removed container
+func (r *Registry) Delete(name string, labels Labels) {
+	r.Lock()
+	defer r.Unlock()
+	if f, ok := r.families[name]; ok {
+		if key, err := labelsKey(labels); err == nil {
+			delete(f.series, key)
 		}
-		time.Sleep(60 * time.Second)
 	}
 }
 
-func writeMetric(metric string) {
-	metric = strings.ToLower(metric)
-	if !fileExists("/metrics") {
-		file, err := os.Create("/metrics")
-		if err != nil {
-			log.Printf("Can't create a file: %v", err)
+// family - existing family or new one of kind, nil when name is invalid or kind differs
+func (r *Registry) family(name, kind string) *family {
+	f, ok := r.families[name]
+	if !ok {
+		if !validMetric.MatchString(name) {
+			log.Printf("Wrong metric name %v", name)
+			return nil
 		}
-		file.Close()
-	} 
+		f = &family{kind: kind, series: map[string]*series{}}
+		r.families[name] = f
+	}
+	if f.kind == "" {
+		f.kind = kind
+	}
+	if kind != "" && f.kind != kind {
+		log.Printf("Metric %v is %v, not %v", name, f.kind, kind)
+		return nil
+	}
+	return f
+}
 
-	input, err := ioutil.ReadFile("/metrics")
+func (r *Registry) series(name, kind string, labels Labels) *series {
+	f := r.family(name, kind)
+	if f == nil {
+		return nil
+	}
+	key, err := labelsKey(labels)
 	if err != nil {
-		log.Fatalln(err)
-	}
-	lines := strings.Split(string(input), "\n")
-	pair := strings.Split(metric, " ")
-	metricExist := false
-	for i, line := range lines {
-		if strings.Contains(line, fmt.Sprintf("%v ",pair[0])) {
-			lines[i] = metric
-			metricExist = true
+		log.Printf("Wrong labels of %v: %v", name, err)
+		return nil
+	}
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: key}
+		f.series[key] = s
+	}
+	return s
+}
+
+// labelsKey - rendered `{a="1",b="2"}` of labels sorted by name, also identity of series
+func labelsKey(labels Labels) (string, error) {
+	if len(labels) == 0 {
+		return "", nil
+	}
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		if !validLabel.MatchString(n) || strings.HasPrefix(n, "__") {
+			return "", fmt.Errorf("wrong label name %v", n)
 		}
+		names = append(names, n)
 	}
-	if metricExist != true {
-		lines = append(lines, metric)
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = fmt.Sprintf(`%v="%v"`, n, escape(labels[n], true))
 	}
-	output := strings.Join(lines, "\n")
-	err = ioutil.WriteFile("/metrics", []byte(output), 0666)
-	if err != nil {
-		log.Fatalln(err)
+	return "{" + strings.Join(pairs, ",") + "}", nil
+}
+
+// escape - backslash and newline escaping of text format, quotes too in label values
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
 	}
+	return s
 }
 
-// WriteToChannel add metrics to queue
-func WriteToChannel(metrics []string, queue chan string) {
-	for _, metric := range metrics {
-		mt := strings.ToLower(metric)
-		queue <- mt
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
 	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
 }
 
-func fileExists(filename string) bool {
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
+// Render - Prometheus text exposition format of all series
+func (r *Registry) Render(w io.Writer) error {
+	r.Lock()
+	names := make([]string, 0, len(r.families))
+	for n := range r.families {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	buf := &bytes.Buffer{}
+	for _, n := range names {
+		f := r.families[n]
+		if len(f.series) == 0 {
+			continue
+		}
+		if f.help != "" {
+			fmt.Fprintf(buf, "# HELP %v %v\n", n, escape(f.help, false))
+		}
+		fmt.Fprintf(buf, "# TYPE %v %v\n", n, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(buf, "%v%v %v\n", n, k, formatValue(f.series[k].value))
+		}
 	}
-	return !info.IsDir()
+	r.Unlock()
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ServeHTTP - scrape endpoint of registry
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Render(w); err != nil {
+		log.Printf("Failed to render metrics: %v", err)
+	}
+}
+
+// Help - describe metric of default registry
+func Help(name, text string) {
+	Default.Help(name, text)
+}
+
+// SetGauge - set gauge of default registry
+func SetGauge(name string, labels Labels, value float64) {
+	Default.SetGauge(name, labels, value)
+}
+
+// AddCounter - increase counter of default registry
+func AddCounter(name string, labels Labels, delta float64) {
+	Default.AddCounter(name, labels, delta)
+}
+
+// SetCounter - set counter of default registry
+func SetCounter(name string, labels Labels, value float64) {
+	Default.SetCounter(name, labels, value)
+}
+
+// Delete - drop series of default registry
+func Delete(name string, labels Labels) {
+	Default.Delete(name, labels)
+}
+
+// Start - serve default registry at addr/metrics
+func Start(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Default)
+	log.Fatal(http.ListenAndServe(addr, mux))
 }
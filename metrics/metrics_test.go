@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestRenderEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.Help("up", "Line one\nback\\slash \"quoted\".")
+	r.SetGauge("up", Labels{"path": "C:\\tmp\n\"x\""}, 1)
+	want := "# HELP up Line one\\nback\\\\slash \"quoted\".\n" +
+		"# TYPE up gauge\n" +
+		"up{path=\"C:\\\\tmp\\n\\\"x\\\"\"} 1\n"
+	if got := render(t, r); got != want {
+		t.Fatalf("render =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderOrder(t *testing.T) {
+	r := NewRegistry()
+	r.SetGauge("b_metric", Labels{"node": "n2"}, 2)
+	r.SetGauge("b_metric", Labels{"node": "n1", "container": "web"}, 1)
+	r.SetGauge("b_metric", Labels{"node": "n1"}, 3)
+	r.AddCounter("a_total", nil, 5)
+	want := "# TYPE a_total counter\n" +
+		"a_total 5\n" +
+		"# TYPE b_metric gauge\n" +
+		"b_metric{container=\"web\",node=\"n1\"} 1\n" +
+		"b_metric{node=\"n1\"} 3\n" +
+		"b_metric{node=\"n2\"} 2\n"
+	if got := render(t, r); got != want {
+		t.Fatalf("render =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestKindConflict(t *testing.T) {
+	r := NewRegistry()
+	r.SetGauge("requests", nil, 1)
+	r.AddCounter("requests", nil, 10)
+	r.SetCounter("requests", nil, 10)
+	r.AddCounter("errors_total", nil, 1)
+	r.SetGauge("errors_total", nil, 7)
+	r.AddCounter("errors_total", nil, -1)
+	want := "# TYPE errors_total counter\n" +
+		"errors_total 1\n" +
+		"# TYPE requests gauge\n" +
+		"requests 1\n"
+	if got := render(t, r); got != want {
+		t.Fatalf("render =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	r := NewRegistry()
+	r.SetGauge("1bad", nil, 1)
+	r.SetGauge("good", Labels{"__reserved": "x"}, 1)
+	r.SetGauge("good", Labels{"bad-label": "x"}, 1)
+	if got := render(t, r); got != "" {
+		t.Fatalf("invalid series rendered:\n%s", got)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r := NewRegistry()
+	r.Help("mem", "Memory.")
+	r.SetGauge("mem", Labels{"container": "a"}, 1)
+	r.SetGauge("mem", Labels{"container": "b"}, 2)
+	r.Delete("mem", Labels{"container": "a"})
+	r.Delete("unknown", Labels{"container": "a"})
+	want := "# HELP mem Memory.\n# TYPE mem gauge\nmem{container=\"b\"} 2\n"
+	if got := render(t, r); got != want {
+		t.Fatalf("render =\n%s\nwant\n%s", got, want)
+	}
+	// family without series is not rendered
+	r.Delete("mem", Labels{"container": "b"})
+	if got := render(t, r); got != "" {
+		t.Fatalf("empty family rendered:\n%s", got)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	r := NewRegistry()
+	r.SetGauge("v", Labels{"k": "inf"}, math.Inf(1))
+	r.SetGauge("v", Labels{"k": "minf"}, math.Inf(-1))
+	r.SetGauge("v", Labels{"k": "nan"}, math.NaN())
+	r.SetGauge("v", Labels{"k": "small"}, 0.000001)
+	r.SetGauge("v", Labels{"k": "big"}, 1e21)
+	want := "# TYPE v gauge\n" +
+		"v{k=\"big\"} 1e+21\n" +
+		"v{k=\"inf\"} +Inf\n" +
+		"v{k=\"minf\"} -Inf\n" +
+		"v{k=\"nan\"} NaN\n" +
+		"v{k=\"small\"} 1e-06\n"
+	if got := render(t, r); got != want {
+		t.Fatalf("render =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSetCounter(t *testing.T) {
+	r := NewRegistry()
+	r.SetCounter("rx_bytes_total", nil, 100)
+	r.AddCounter("rx_bytes_total", nil, 5)
+	r.SetCounter("rx_bytes_total", nil, 200)
+	if got := render(t, r); got != "# TYPE rx_bytes_total counter\nrx_bytes_total 200\n" {
+		t.Fatalf("render =\n%s", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	kv "dockerator/kvstore"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// auditEntry - record of mutating operation, entries are never changed or removed
+type auditEntry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target"`
+	Payload string    `json:"payload,omitempty"`
+	Result  string    `json:"result"`
+}
+
+// context keys handlers set to name object they change and to replace sensitive payload
+const (
+	auditTarget  = "auditTarget"
+	auditPayload = "auditPayload"
+)
+
+// recordAudit - append entry, "Audit-<xid>" keys keep them in time order
+func recordAudit(actor, action, target, payload, result string) {
+	key := nameWithSuffix("Audit")
+	entry := auditEntry{ID: key, Time: time.Now(), Actor: actor, Action: action, Target: target, Payload: payload, Result: result}
+	if err := kv.PutJSON(db, key, entry); err != nil {
+		log.Printf("Failed to record audit entry %v %v by %v: %v", action, target, actor, err)
+	}
+}
+
+// auditLog - record every mutating REST call with its result
+func auditLog(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+			return next(c)
+		}
+		var body []byte
+		if req.Body != nil {
+			body, _ = ioutil.ReadAll(req.Body)
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		err := next(c)
+		status := c.Response().Status
+		if err != nil {
+			status = http.StatusInternalServerError
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+		}
+		actor, _ := c.Get("user").(string)
+		if actor == "" {
+			actor = "anonymous"
+		}
+		if p, ok := c.Get(auditPayload).(string); ok {
+			body = []byte(p)
+		}
+		target, _ := c.Get(auditTarget).(string)
+		if target == "" {
+			target = req.URL.Path
+		}
+		recordAudit(actor, fmt.Sprintf("%v %v", req.Method, c.Path()), target, string(body), fmt.Sprintf("%v %v", status, http.StatusText(status)))
+		return err
+	}
+}
+
+// auditDispatch - record task handed to node
+func auditDispatch(node string, task kv.Task) {
+	payload, _ := json.Marshal(task)
+	recordAudit("node:"+node, "dispatch "+task.Job, task.Container, string(payload), "dispatched")
+}
+
+// audit - entries filtered by ?since=&until= (RFC3339) and ?actor=
+func audit(c echo.Context) error {
+	var since, until time.Time
+	for param, t := range map[string]*time.Time{"since": &since, "until": &until} {
+		if v := c.QueryParam(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return c.String(http.StatusBadRequest, fmt.Sprintf("Wrong %v, RFC3339 expected", param))
+			}
+			*t = parsed
+		}
+	}
+	actor := c.QueryParam("actor")
+	entries := []auditEntry{}
+	keys := kv.KeysList(db, "Audit-")
+	sort.Strings(keys)
+	for _, k := range keys {
+		e := auditEntry{}
+		if err := kv.GetJSON(db, k, &e); err != nil {
+			log.Printf("Failed to decode audit entry %v: %v", k, err)
+			continue
+		}
+		if (!since.IsZero() && e.Time.Before(since)) || (!until.IsZero() && e.Time.After(until)) {
+			continue
+		}
+		if actor != "" && e.Actor != actor {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func auditEntries(t *testing.T, e *echo.Echo, query url.Values) (entries []auditEntry) {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("audit %v = %v %v", query, rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestAuditRedactsCredentials(t *testing.T) {
+	testStore(t)
+	testSecretsKey(t)
+	e := echo.New()
+	e.Use(auditLog)
+	// actor is set by auth middleware of routes
+	as := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user", "alice")
+			return next(c)
+		}
+	}
+	e.POST("/secrets", secretCreate, as)
+	e.POST("/registries", registryCreate, as)
+	e.GET("/audit", audit)
+
+	for _, r := range []struct{ path, body string }{
+		{"/secrets", `{"name":"db","value":"hunter2"}`},
+		{"/registries", `{"name":"hub","server":"registry.example.com","username":"bot","password":"hunter2"}`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, r.path, strings.NewReader(r.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %v = %v %v", r.path, rec.Code, rec.Body)
+		}
+	}
+	entries := auditEntries(t, e, nil)
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	for _, en := range entries {
+		if strings.Contains(en.Payload, "hunter2") {
+			t.Errorf("%v recorded credential: %v", en.Action, en.Payload)
+		}
+		if en.Actor != "alice" || en.Result != "200 OK" {
+			t.Errorf("entry = %+v", en)
+		}
+	}
+	if entries[0].Payload != `{"name":"db"}` || entries[1].Payload != `{"name":"hub","server":"registry.example.com","username":"bot"}` {
+		t.Errorf("payloads = %q, %q", entries[0].Payload, entries[1].Payload)
+	}
+}
+
+func TestAuditFilters(t *testing.T) {
+	testStore(t)
+	e := echo.New()
+	e.GET("/audit", audit)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, actor := range []string{"alice", "bob", "alice", "node:n1"} {
+		key := nameWithSuffix("Audit")
+		kv.PutJSON(db, key, auditEntry{ID: key, Time: base.Add(time.Duration(i) * time.Hour), Actor: actor, Action: "POST /service", Result: "200 OK"})
+	}
+	for _, c := range []struct {
+		query url.Values
+		want  int
+	}{
+		{url.Values{}, 4},
+		{url.Values{"actor": {"alice"}}, 2},
+		{url.Values{"actor": {"carol"}}, 0},
+		{url.Values{"since": {base.Add(time.Hour).Format(time.RFC3339)}}, 3},
+		{url.Values{"until": {base.Add(time.Hour).Format(time.RFC3339)}}, 2},
+		{url.Values{"since": {base.Add(time.Hour).Format(time.RFC3339)}, "until": {base.Add(2 * time.Hour).Format(time.RFC3339)}, "actor": {"alice"}}, 1},
+	} {
+		entries := auditEntries(t, e, c.query)
+		if len(entries) != c.want {
+			t.Errorf("%v: %v entries, want %v", c.query.Encode(), len(entries), c.want)
+		}
+		for i := 1; i < len(entries); i++ {
+			if entries[i].Time.Before(entries[i-1].Time) {
+				t.Errorf("%v: entries out of order", c.query.Encode())
+			}
+		}
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit?since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("wrong since = %v", rec.Code)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	kv "dockerator/kvstore"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// API roles, each includes permissions of previous ones
+const (
+	roleViewer   = "viewer"
+	roleDeployer = "deployer"
+	roleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{
+	roleViewer:   1,
+	roleDeployer: 2,
+	roleAdmin:    3,
+}
+
+// apiToken - stored REST API token, secret part is kept only as hash
+type apiToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Namespace string    `json:"namespace,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+type apiTokenRequest struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Namespace string `json:"namespace"`
+}
+
+func apiTokenKey(id string) string {
+	return "APIToken-" + id
+}
+
+// createAPIToken - new "<id>.<secret>" token of role, limited to namespace if given
+func createAPIToken(name, role, namespace string) (token string, t apiToken, err error) {
+	id, secret := randomHex(3), randomHex(16)
+	t = apiToken{ID: id, Name: name, Role: role, Namespace: namespace, Hash: hashSecret(secret), Created: time.Now()}
+	err = kv.PutJSON(db, apiTokenKey(id), t)
+	return fmt.Sprintf("%v.%v", id, secret), t, err
+}
+
+// initAuth - print admin token on first start, when no static one is set
+func initAuth() {
+	if *adminToken != "" || !isLeader() || len(kv.KeysList(db, apiTokenKey(""))) > 0 {
+		return
+	}
+	token, _, err := createAPIToken("admin", roleAdmin, "")
+	if err != nil {
+		log.Fatalf("failed to create admin token: %v", err)
+	}
+	log.Printf("Initial admin API token: %v", token)
+}
+
+// authenticate - find token of "Authorization: Bearer <token>" header
+func authenticate(c echo.Context) (t apiToken, err error) {
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return t, fmt.Errorf("no bearer token")
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if *adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(*adminToken)) == 1 {
+		return apiToken{ID: "static", Name: "admin", Role: roleAdmin}, nil
+	}
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !kv.KeyExist(db, apiTokenKey(parts[0])) {
+		return t, fmt.Errorf("unknown token")
+	}
+	if err = kv.GetJSON(db, apiTokenKey(parts[0]), &t); err != nil {
+		return t, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(t.Hash)) != 1 {
+		return t, fmt.Errorf("wrong token")
+	}
+	return t, nil
+}
+
+// requireRole - allow route to tokens with role or higher, namespaced tokens only to routes of their namespace
+func requireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			t, err := authenticate(c)
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="dockerator"`)
+				return c.String(http.StatusUnauthorized, "Unauthorized")
+			}
+			if roleRank[t.Role] < roleRank[role] {
+				log.Printf("Denied %v %v to %v (%v)", c.Request().Method, c.Path(), t.Name, t.Role)
+				return c.String(http.StatusForbidden, "Forbidden")
+			}
+			if t.Namespace != "" && c.Param("ns") != t.Namespace {
+				log.Printf("Denied %v %v to %v of namespace %v", c.Request().Method, c.Path(), t.Name, t.Namespace)
+				return c.String(http.StatusForbidden, "Forbidden")
+			}
+			c.Set("user", t.Name)
+			c.Set("namespace", t.Namespace)
+			return next(c)
+		}
+	}
+}
+
+func apiTokenCreate(c echo.Context) error {
+	req := apiTokenRequest{}
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	if _, ok := roleRank[req.Role]; !ok {
+		return c.String(http.StatusBadRequest, "Unknown role")
+	}
+	if req.Namespace != "" && !namespaceExists(req.Namespace) {
+		return c.String(http.StatusBadRequest, "Unknown namespace")
+	}
+	token, t, err := createAPIToken(req.Name, req.Role, req.Namespace)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	log.Printf("API token %v of %v (%v) created", t.ID, t.Name, t.Role)
+	return c.JSON(http.StatusOK, map[string]string{"id": t.ID, "name": t.Name, "role": t.Role, "namespace": t.Namespace, "token": token})
+}
+
+func apiTokensList(c echo.Context) error {
+	tokens := []apiToken{}
+	for _, k := range kv.KeysList(db, apiTokenKey("")) {
+		t := apiToken{}
+		if err := kv.GetJSON(db, k, &t); err != nil {
+			log.Printf("Failed to decode API token %v: %v", k, err)
+			continue
+		}
+		t.Hash = ""
+		tokens = append(tokens, t)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func apiTokenRevoke(c echo.Context) error {
+	id := c.Param("id")
+	if !kv.KeyExist(db, apiTokenKey(id)) {
+		return c.String(http.StatusNotFound, "Unknown token")
+	}
+	if !kv.DeleteKV(db, apiTokenKey(id)) {
+		return c.String(http.StatusInternalServerError, "Failed to revoke token")
+	}
+	log.Printf("API token %v revoked", id)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// httpScheme - scheme of REST API of cluster members
+func httpScheme() string {
+	if *httpTLS || *httpCert != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// startHTTP - serve REST API, over TLS with given or cluster CA issued certificate
+func startHTTP(e *echo.Echo) error {
+	switch {
+	case *httpCert != "":
+		return e.StartTLS(*httpAddr, *httpCert, *httpKey)
+	case *httpTLS:
+		s := &http.Server{
+			Addr: *httpAddr,
+			TLSConfig: &tls.Config{
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+					return currentMemberCert()
+				},
+				MinVersion: tls.VersionTLS12,
+			},
+		}
+		return e.StartServer(s)
+	}
+	return e.Start(*httpAddr)
+}
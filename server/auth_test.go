@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequireRole(t *testing.T) {
+	testStore(t)
+	*adminToken = "static-admin"
+	defer func() { *adminToken = "" }()
+	tokens := map[string]string{"static": "static-admin", "wrong": "000000.00", "none": ""}
+	for _, tok := range []struct{ name, role, ns string }{
+		{"viewer", roleViewer, ""},
+		{"deployer", roleDeployer, ""},
+		{"admin", roleAdmin, ""},
+		{"team-deployer", roleDeployer, "team"},
+		{"team-admin", roleAdmin, "team"},
+	} {
+		token, _, err := createAPIToken(tok.name, tok.role, tok.ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[tok.name] = token
+	}
+	// wrong secret of existing token
+	tokens["forged"] = tokens["admin"][:7] + "00"
+
+	ok := func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("user").(string))
+	}
+	e := echo.New()
+	e.GET("/state", ok, requireRole(roleViewer))
+	e.POST("/service", ok, requireRole(roleDeployer))
+	e.POST("/namespaces/:ns/service", ok, requireRole(roleDeployer))
+	e.DELETE("/nodes/:name", ok, requireRole(roleAdmin))
+
+	for _, c := range []struct {
+		token, method, path string
+		code                int
+	}{
+		{"none", http.MethodGet, "/state", http.StatusUnauthorized},
+		{"wrong", http.MethodGet, "/state", http.StatusUnauthorized},
+		{"forged", http.MethodGet, "/state", http.StatusUnauthorized},
+		{"viewer", http.MethodGet, "/state", http.StatusOK},
+		{"viewer", http.MethodPost, "/service", http.StatusForbidden},
+		{"deployer", http.MethodPost, "/service", http.StatusOK},
+		{"deployer", http.MethodPost, "/namespaces/team/service", http.StatusOK},
+		{"deployer", http.MethodDelete, "/nodes/n1", http.StatusForbidden},
+		{"admin", http.MethodDelete, "/nodes/n1", http.StatusOK},
+		{"static", http.MethodDelete, "/nodes/n1", http.StatusOK},
+		// namespaced tokens only reach routes of own namespace
+		{"team-deployer", http.MethodPost, "/namespaces/team/service", http.StatusOK},
+		{"team-deployer", http.MethodPost, "/namespaces/other/service", http.StatusForbidden},
+		{"team-deployer", http.MethodPost, "/service", http.StatusForbidden},
+		{"team-deployer", http.MethodGet, "/state", http.StatusForbidden},
+		{"team-admin", http.MethodDelete, "/nodes/n1", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if tokens[c.token] != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokens[c.token])
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%v %v %v = %v, want %v", c.token, c.method, c.path, rec.Code, c.code)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get(echo.HeaderWWWAuthenticate) == "" {
+			t.Errorf("%v %v %v: no WWW-Authenticate", c.token, c.method, c.path)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// autoscalePolicy - replicas range and utilization targets in percent of spec cpus and memory
+type autoscalePolicy struct {
+	Min          int     `json:"min"`
+	Max          int     `json:"max"`
+	TargetCPU    float64 `json:"target_cpu,omitempty"`
+	TargetMemory float64 `json:"target_memory,omitempty"`
+	Cooldown     int     `json:"cooldown,omitempty"`
+}
+
+// utilization within tolerance of target keeps replicas
+const autoscaleTolerance = 0.1
+
+const defaultCooldown = 5 * time.Minute
+
+func autoscaleKey(service string) string {
+	return "Autoscale-" + service
+}
+
+// checkAutoscale - error if policy can't be evaluated for spec
+func checkAutoscale(spec svcConfig) error {
+	p := spec.Autoscale
+	switch {
+	case p == nil:
+		return nil
+	case p.Min < 1 || p.Max < p.Min:
+		return errors.New("autoscale needs 1 <= min <= max")
+	case p.TargetCPU <= 0 && p.TargetMemory <= 0:
+		return errors.New("autoscale needs target_cpu or target_memory")
+	case p.TargetCPU < 0 || p.TargetMemory < 0 || p.Cooldown < 0:
+		return errors.New("wrong autoscale policy")
+	case p.TargetMemory > 0 && spec.Memory == 0:
+		return errors.New("target_memory needs memory limit of service")
+	}
+	return nil
+}
+
+// clampReplicas - replicas within autoscale range
+func clampReplicas(p *autoscalePolicy, rs int) int {
+	if rs < p.Min {
+		return p.Min
+	}
+	if rs > p.Max {
+		return p.Max
+	}
+	return rs
+}
+
+// serviceUtilization - average percent of spec cpus (one core if unset) and memory of running replicas with stats
+func serviceUtilization(name string, spec svcConfig, reported map[string]reportedContainer) (cpu, memory float64, sampled int) {
+	cpus := spec.CPUs
+	if cpus == 0 {
+		cpus = 1
+	}
+	for _, c := range kv.GetList(db, kv.ServiceKey(name)) {
+		r, ok := reported[c]
+		// agent sends stats after its first round, memory of running container is never zero
+		if !ok || r.GetState() != "running" || r.GetMemoryUsage() == 0 {
+			continue
+		}
+		sampled++
+		cpu += r.GetCpuPercent() / cpus
+		if spec.Memory > 0 {
+			memory += float64(r.GetMemoryUsage()) / float64(spec.Memory<<20) * 100
+		}
+	}
+	if sampled > 0 {
+		cpu, memory = cpu/float64(sampled), memory/float64(sampled)
+	}
+	return
+}
+
+// desiredReplicas - replicas bringing average utilization of sampled replicas to target, the largest of cpu and memory
+// proposals, service isn't scaled down until all its replicas are sampled
+func desiredReplicas(p *autoscalePolicy, current, sampled int, cpu, memory float64) int {
+	if sampled == 0 {
+		return clampReplicas(p, current)
+	}
+	desired := 0
+	for _, m := range []struct{ util, target float64 }{{cpu, p.TargetCPU}, {memory, p.TargetMemory}} {
+		if m.target <= 0 {
+			continue
+		}
+		proposal := current
+		if ratio := m.util / m.target; math.Abs(ratio-1) > autoscaleTolerance {
+			proposal = int(math.Ceil(float64(current) * ratio))
+		}
+		if sampled < current && proposal < current {
+			proposal = current
+		}
+		if proposal > desired {
+			desired = proposal
+		}
+	}
+	return clampReplicas(p, desired)
+}
+
+// autoscaleLoop - evaluate policies of services on leader
+func autoscaleLoop() {
+	for {
+		time.Sleep(*autoscaleInterval)
+		if !isLeader() {
+			continue
+		}
+		reported := reportedContainers()
+		for _, s := range kv.GetList(db, "Services") {
+			if spec := serviceSpec(s); spec.Autoscale != nil {
+				autoscale(s, spec, reported)
+			}
+		}
+	}
+}
+
+// autoscale - scale service like PUT scale does, at most once per cooldown
+func autoscale(name string, spec svcConfig, reported map[string]reportedContainer) {
+	p := spec.Autoscale
+	cooldown := time.Duration(p.Cooldown) * time.Second
+	if cooldown == 0 {
+		cooldown = defaultCooldown
+	}
+	last := time.Time{}
+	if kv.KeyExist(db, autoscaleKey(name)) {
+		kv.GetJSON(db, autoscaleKey(name), &last)
+	}
+	if time.Since(last) < cooldown {
+		return
+	}
+	cpu, memory, sampled := serviceUtilization(name, spec, reported)
+	desired := desiredReplicas(p, spec.Replicas, sampled, cpu, memory)
+	if desired == spec.Replicas {
+		return
+	}
+	kv.PutJSON(db, autoscaleKey(name), time.Now())
+	spec, err := scaleSpec(name, desired)
+	if _, ok := err.(quotaError); ok {
+		log.Printf("Autoscale of %v to %v blocked: %v", name, desired, err)
+		recordEvent("autoscale-blocked", name, "", fmt.Sprintf("scale from %v to %v replicas blocked: %v", spec.Replicas, desired, err))
+		return
+	}
+	if err != nil {
+		log.Printf("Autoscale of %v to %v failed: %v", name, desired, err)
+		return
+	}
+	scaleService(name, spec, desired)
+	recordEvent("service-scaled", name, "", fmt.Sprintf("autoscaled from %v to %v replicas, cpu %.0f%%, memory %.0f%% of %v sampled replicas",
+		spec.Replicas, desired, cpu, memory, sampled))
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestDesiredReplicas(t *testing.T) {
+	cpu := &autoscalePolicy{Min: 1, Max: 10, TargetCPU: 50}
+	both := &autoscalePolicy{Min: 2, Max: 10, TargetCPU: 50, TargetMemory: 80}
+	for _, c := range []struct {
+		name             string
+		p                *autoscalePolicy
+		current, sampled int
+		cpu, memory      float64
+		want             int
+	}{
+		{"no stats keeps replicas", cpu, 3, 0, 0, 0, 3},
+		{"no stats within range", both, 1, 0, 0, 0, 2},
+		{"within tolerance", cpu, 4, 4, 54, 0, 4},
+		{"scale up", cpu, 2, 2, 100, 0, 4},
+		{"scale up from partial sample", cpu, 4, 2, 100, 0, 8},
+		{"scale down", cpu, 4, 4, 20, 0, 2},
+		{"no scale down from partial sample", cpu, 4, 3, 10, 0, 4},
+		{"max", cpu, 4, 4, 500, 0, 10},
+		{"min", cpu, 4, 4, 1, 0, 1},
+		{"larger of cpu and memory", both, 4, 4, 25, 120, 6},
+		{"memory keeps cpu from scaling down", both, 4, 4, 10, 80, 4},
+	} {
+		if got := desiredReplicas(c.p, c.current, c.sampled, c.cpu, c.memory); got != c.want {
+			t.Errorf("%v: desiredReplicas = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	kv "dockerator/kvstore"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	caKey    = "CA"
+	caTTL    = 10 * 365 * 24 * time.Hour
+	nodeOU   = "node"
+	memberOU = "server"
+)
+
+// caRecord - CA cert and key shared by all cluster members through the store,
+// key is sealed with -secrets-key, plain Key is left only by versions which did not require it
+type caRecord struct {
+	Cert      string `json:"cert"`
+	Key       string `json:"key,omitempty"`
+	SealedKey string `json:"sealed_key,omitempty"`
+}
+
+// nodeCert - serials of certificates issued to node and id of token it joined with,
+// record of removed node revokes all of them
+type nodeCert struct {
+	Token   string   `json:"token,omitempty"`
+	Serials []string `json:"serials,omitempty"`
+	Revoked bool     `json:"revoked,omitempty"`
+}
+
+func nodeCertKey(node string) string {
+	return "NodeCert-" + node
+}
+
+// ca - internal CA issuing node and member certificates
+var ca struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pem  []byte
+	pool *x509.CertPool
+}
+
+// memberCert - certificate of this server for gRPC listener and calls to leader
+var memberCert = struct {
+	sync.Mutex
+	cert *tls.Certificate
+}{}
+
+// initCA - leader creates CA on first start, followers wait for replicated one
+func initCA() {
+	if secretsAEAD == nil {
+		log.Fatal("CA key is stored only sealed, set -secrets-key")
+	}
+	for !kv.KeyExist(db, caKey) {
+		if isLeader() {
+			if err := createCA(); err != nil {
+				log.Fatalf("failed to create CA: %v", err)
+			}
+			log.Println("Created cluster CA")
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	rec := caRecord{}
+	if err := kv.GetJSON(db, caKey, &rec); err != nil {
+		log.Fatalf("failed to load CA: %v", err)
+	}
+	keyPEM, err := caKeyPEM(rec)
+	if err != nil {
+		log.Fatalf("failed to load CA: %v", err)
+	}
+	certBlock, _ := pem.Decode([]byte(rec.Cert))
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		log.Fatal("failed to load CA: wrong PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		log.Fatalf("failed to load CA: %v", err)
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		log.Fatalf("failed to load CA: %v", err)
+	}
+	ca.cert, ca.key, ca.pem = cert, key, []byte(rec.Cert)
+	// CA created before -secrets-key was required gets its key sealed
+	if rec.Key != "" && isLeader() {
+		if err := storeCA(rec.Cert, keyPEM); err != nil {
+			log.Printf("Failed to seal CA key: %v", err)
+		} else {
+			log.Println("Sealed CA key with secrets key")
+		}
+	}
+	ca.pool = x509.NewCertPool()
+	ca.pool.AddCert(cert)
+	if err := rotateMemberCert(); err != nil {
+		log.Fatalf("failed to issue server certificate: %v", err)
+	}
+}
+
+func createCA() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "dockerator CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(caTTL),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return storeCA(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+// storeCA - keep CA in the store, key is never stored unsealed
+func storeCA(cert string, keyPEM []byte) error {
+	sealed, err := encryptSecret(caKey, keyPEM)
+	if err != nil {
+		return err
+	}
+	return kv.PutJSON(db, caKey, caRecord{Cert: cert, SealedKey: sealed})
+}
+
+func caKeyPEM(rec caRecord) ([]byte, error) {
+	if rec.SealedKey == "" {
+		return []byte(rec.Key), nil
+	}
+	if secretsAEAD == nil {
+		return nil, errors.New("CA key is sealed, set -secrets-key")
+	}
+	return decrypt(caKey, rec.SealedKey)
+}
+
+func serialNumber() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		log.Fatalf("failed to read random: %v", err)
+	}
+	return n
+}
+
+// issueCert - sign node CSR, identity comes from server not from CSR subject, serial is recorded for node
+func issueCert(csrDER []byte, node, token string) ([]byte, error) {
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	serial := serialNumber()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: node, OrganizationalUnit: []string{nodeOU}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(*nodeCertTTL),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordNodeCert(node, token, serial.Text(16)); err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// recordNodeCert - remember serial of issued certificate, previous one stays valid until agent switches to new one
+func recordNodeCert(node, token, serial string) error {
+	return kv.Update(db, func(b *kv.Batch) error {
+		rec := nodeCert{}
+		b.GetJSON(nodeCertKey(node), &rec)
+		if rec.Revoked {
+			rec = nodeCert{}
+		}
+		if token != "" {
+			rec.Token = token
+		}
+		rec.Serials = append([]string{serial}, rec.Serials...)
+		if len(rec.Serials) > 2 {
+			rec.Serials = rec.Serials[:2]
+		}
+		return b.PutJSON(nodeCertKey(node), rec)
+	})
+}
+
+// certRevoked - node was removed or certificate was superseded, certificates issued before serials were recorded pass
+func certRevoked(node string, cert *x509.Certificate) bool {
+	rec := nodeCert{}
+	if err := kv.GetJSON(db, nodeCertKey(node), &rec); err != nil {
+		return false
+	}
+	return rec.Revoked || !contains(rec.Serials, cert.SerialNumber.Text(16))
+}
+
+// rotateMemberCert - issue fresh certificate of this server
+func rotateMemberCert() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: "dockerator-server-" + *nodeID, OrganizationalUnit: []string{memberOU}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(*nodeCertTTL),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, h := range tlsHosts() {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	memberCert.Lock()
+	defer memberCert.Unlock()
+	memberCert.cert = &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+	return nil
+}
+
+// tlsHosts - names agents and members dial this server by
+func tlsHosts() (hosts []string) {
+	hosts = []string{"localhost", "127.0.0.1", *advertiseHost}
+	if host, _, err := net.SplitHostPort(*grpcAddr); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+	for _, h := range strings.Split(*tlsSAN, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return
+}
+
+func currentMemberCert() (*tls.Certificate, error) {
+	memberCert.Lock()
+	defer memberCert.Unlock()
+	if memberCert.cert == nil {
+		return nil, errors.New("no server certificate")
+	}
+	return memberCert.cert, nil
+}
+
+// expiring - less than third of certificate lifetime left
+func expiring(cert *x509.Certificate) bool {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return time.Until(cert.NotAfter) < lifetime/3
+}
+
+// certRotationLoop - reissue server certificate before it expires
+func certRotationLoop() {
+	for {
+		time.Sleep(time.Minute)
+		cert, err := currentMemberCert()
+		if err == nil && !expiring(cert.Leaf) {
+			continue
+		}
+		if err := rotateMemberCert(); err != nil {
+			log.Printf("Failed to rotate server certificate: %v", err)
+			continue
+		}
+		log.Println("Rotated server certificate")
+	}
+}
+
+// serverTLS - gRPC listener config, client certificate is optional only for Join
+func serverTLS() *tls.Config {
+	return &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  ca.pool,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return currentMemberCert()
+		},
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// memberTLS - config of calls forwarded to leader
+func memberTLS() *tls.Config {
+	return &tls.Config{
+		RootCAs: ca.pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return currentMemberCert()
+		},
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// caCert - CA certificate agents use to verify servers
+func caCert(c echo.Context) error {
+	return c.Blob(http.StatusOK, "application/x-pem-file", ca.pem)
+}
+
+// certificateIdentity - node name and whether peer is cluster member
+func certificateIdentity(cert *x509.Certificate) (name string, member bool, err error) {
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		switch ou {
+		case memberOU:
+			return cert.Subject.CommonName, true, nil
+		case nodeOU:
+			return cert.Subject.CommonName, false, nil
+		}
+	}
+	return "", false, fmt.Errorf("unknown certificate %v", cert.Subject)
+}
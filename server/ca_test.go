@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	kv "dockerator/kvstore"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// testCA - store with cluster CA created by standalone leader
+func testCA(t *testing.T) {
+	testStore(t)
+	testSecretsKey(t)
+	initCA()
+}
+
+// nodeCertificate - certificate issued for node on CSR claiming another name
+func nodeCertificate(t *testing.T, node, token string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "other"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := issueCert(csr, node, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("issued certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCAKeySealed(t *testing.T) {
+	testCA(t)
+	rec := caRecord{}
+	if err := kv.GetJSON(db, caKey, &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Key != "" || rec.SealedKey == "" {
+		t.Fatalf("CA key stored unsealed: %+v", rec)
+	}
+}
+
+func TestIssueCert(t *testing.T) {
+	testCA(t)
+	cert := nodeCertificate(t, "n1", "t1")
+	name, member, err := certificateIdentity(cert)
+	if err != nil || member || name != "n1" {
+		t.Fatalf("identity = %v, %v, %v", name, member, err)
+	}
+	opts := x509.VerifyOptions{Roots: ca.pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	if _, err := cert.Verify(opts); err != nil {
+		t.Fatalf("certificate not signed by CA: %v", err)
+	}
+	if d := time.Until(cert.NotAfter); d > *nodeCertTTL || d < *nodeCertTTL-time.Hour {
+		t.Fatalf("certificate lives %v", d)
+	}
+	rec := nodeCert{}
+	kv.GetJSON(db, nodeCertKey("n1"), &rec)
+	if rec.Token != "t1" || len(rec.Serials) != 1 || certRevoked("n1", cert) {
+		t.Fatalf("record = %+v", rec)
+	}
+}
+
+func TestRenewedCertSupersedes(t *testing.T) {
+	testCA(t)
+	first := nodeCertificate(t, "n1", "t1")
+	// renewal keeps token, previous certificate works until agent switches
+	second := nodeCertificate(t, "n1", "")
+	if certRevoked("n1", first) || certRevoked("n1", second) {
+		t.Fatal("previous certificate rejected right after renewal")
+	}
+	third := nodeCertificate(t, "n1", "")
+	if !certRevoked("n1", first) {
+		t.Fatal("superseded certificate accepted")
+	}
+	if certRevoked("n1", second) || certRevoked("n1", third) {
+		t.Fatal("current certificates rejected")
+	}
+	rec := nodeCert{}
+	kv.GetJSON(db, nodeCertKey("n1"), &rec)
+	if rec.Token != "t1" {
+		t.Fatalf("token = %q", rec.Token)
+	}
+	// certificates of other nodes are not affected
+	if certRevoked("n2", nodeCertificate(t, "n2", "t2")) {
+		t.Fatal("certificate of n2 rejected")
+	}
+}
+
+func TestNodeDeleteRevokes(t *testing.T) {
+	testCA(t)
+	token, tok, err := createToken(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admitNode(token, "n1"); err != nil {
+		t.Fatal(err)
+	}
+	cert := nodeCertificate(t, "n1", tok.ID)
+	kv.AppendKV(db, "Nodes", "n1")
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/nodes/n1", nil), rec)
+	c.SetParamNames("name")
+	c.SetParamValues("n1")
+	if err := nodeDelete(c); err != nil || rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %v, %v", rec.Code, err)
+	}
+	if !certRevoked("n1", cert) {
+		t.Fatal("certificate of removed node accepted")
+	}
+	if kv.KeyExist(db, tokenKey(tok.ID)) {
+		t.Fatal("token of removed node kept")
+	}
+	// node joining again with new token gets valid certificate, old one stays revoked
+	rejoined := nodeCertificate(t, "n1", "t2")
+	if certRevoked("n1", rejoined) || !certRevoked("n1", cert) {
+		t.Fatal("rejoined node certificates")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"dockerator/cluster"
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+var member cluster.Cluster
+
+var leaderConn struct {
+	sync.Mutex
+	addr string
+	conn *grpc.ClientConn
+}
+
+type joinRequest struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+	HTTP string `json:"http"`
+	GRPC string `json:"grpc"`
+}
+
+func clusterConfig() cluster.Config {
+	return cluster.Config{
+		ID:        *nodeID,
+		RaftAddr:  *raftAddr,
+		RaftDir:   *raftDir,
+		HTTPAddr:  advertised(*httpAddr),
+		GRPCAddr:  advertised(*grpcAddr),
+		Bootstrap: *bootstrap,
+	}
+}
+
+func startCluster() {
+	n, err := cluster.Start(clusterConfig(), db)
+	if err != nil {
+		log.Fatalf("failed to start cluster member: %v", err)
+	}
+	member = n
+	kv.SetReplicator(n.Apply)
+	if *joinAddr != "" {
+		go joinCluster(*joinAddr)
+	}
+}
+
+// startEtcdCluster - servers sharing etcd store elect leader through etcd
+func startEtcdCluster(store *kv.EtcdStore) {
+	n, err := cluster.StartEtcd(clusterConfig(), store.Client())
+	if err != nil {
+		log.Fatalf("failed to start cluster member: %v", err)
+	}
+	member = n
+}
+
+// isLeader - standalone server always leads
+func isLeader() bool {
+	return member == nil || member.IsLeader()
+}
+
+func advertised(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort(*advertiseHost, port)
+}
+
+func joinCluster(addr string) {
+	body, _ := json.Marshal(joinRequest{*nodeID, *raftAddr, advertised(*httpAddr), advertised(*grpcAddr)})
+	url := fmt.Sprintf("%v://%v/cluster/join", httpScheme(), addr)
+	client, err := joinClient()
+	if err != nil {
+		log.Fatalf("failed to join cluster: %v", err)
+	}
+	for {
+		req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+*adminToken)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				log.Printf("Joined cluster via %v", addr)
+				return
+			}
+			err = fmt.Errorf("status %v", resp.Status)
+		}
+		log.Printf("Failed to join cluster via %v: %v", addr, err)
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// joinClient - REST client of member to join, cluster CA is not replicated to this member yet
+func joinClient() (*http.Client, error) {
+	if *joinCA == "" {
+		return http.DefaultClient, nil
+	}
+	data, err := ioutil.ReadFile(*joinCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %v", *joinCA)
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}, nil
+}
+
+func clusterStatus(c echo.Context) error {
+	if member == nil {
+		return c.JSON(http.StatusOK, map[string]string{"state": "Standalone"})
+	}
+	return c.JSON(http.StatusOK, member.Status())
+}
+
+func clusterJoin(c echo.Context) error {
+	if member == nil {
+		return c.String(http.StatusBadRequest, "Clustering is disabled")
+	}
+	req := joinRequest{}
+	if err := c.Bind(&req); err != nil || req.ID == "" || req.Addr == "" || req.HTTP == "" || req.GRPC == "" {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	if err := member.Join(cluster.Member{ID: req.ID, HTTP: req.HTTP, GRPC: req.GRPC}, req.Addr); err != nil {
+		log.Printf("Failed to add %v to cluster: %v", req.ID, err)
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, member.Status())
+}
+
+// leaderRedirect - send mutating API calls from followers to the leader
+func leaderRedirect(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Method == http.MethodGet || isLeader() {
+			return next(c)
+		}
+		leader, err := member.Leader()
+		if err != nil {
+			return c.String(http.StatusServiceUnavailable, "No cluster leader")
+		}
+		return c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%v://%v%v", httpScheme(), leader.HTTP, c.Request().RequestURI))
+	}
+}
+
+// leaderClient - gRPC client of current leader for forwarding agent calls
+func leaderClient() (pb.DockeratorClient, error) {
+	leader, err := member.Leader()
+	if err != nil {
+		return nil, err
+	}
+	leaderConn.Lock()
+	defer leaderConn.Unlock()
+	if leaderConn.conn == nil || leaderConn.addr != leader.GRPC {
+		if leaderConn.conn != nil {
+			leaderConn.conn.Close()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		conn, err := grpc.DialContext(ctx, leader.GRPC, grpc.WithTransportCredentials(credentials.NewTLS(memberTLS())), grpc.WithBlock())
+		if err != nil {
+			leaderConn.conn = nil
+			return nil, err
+		}
+		leaderConn.addr, leaderConn.conn = leader.GRPC, conn
+	}
+	return pb.NewDockeratorClient(leaderConn.conn), nil
+}
+
+// forwarded - context of call forwarded to leader, keeps identity of calling node
+func forwarded(ctx context.Context) context.Context {
+	node, _ := callerNode(ctx)
+	return metadata.AppendToOutgoingContext(ctx, nodeMD, node)
+}
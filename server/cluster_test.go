@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMember struct {
+	id, http string
+	cmd      *exec.Cmd
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// testKey - -secrets-key shared by test members, CA key is stored sealed by it
+const testKey = "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s="
+
+// startMember - server process with own db and raft dir, joining first member unless it bootstraps
+func startMember(t *testing.T, bin, id, join string) *testMember {
+	dir := filepath.Join(t.TempDir(), id)
+	m := &testMember{id: id, http: freeAddr(t)}
+	key := filepath.Join(t.TempDir(), "secrets.key")
+	if err := os.WriteFile(key, []byte(testKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-id", id, "-http", m.http, "-grpc", freeAddr(t), "-raft", freeAddr(t),
+		"-raft-dir", filepath.Join(dir, "raft"), "-db", filepath.Join(dir, "db"), "-admin-token", "test", "-secrets-key", key}
+	if join == "" {
+		args = append(args, "-bootstrap")
+	} else {
+		args = append(args, "-join", join)
+	}
+	m.cmd = exec.Command(bin, args...)
+	log, err := os.Create(filepath.Join(t.TempDir(), id+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.cmd.Stdout, m.cmd.Stderr = log, log
+	if err := m.cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.cmd.Process.Kill()
+		m.cmd.Wait()
+		if t.Failed() {
+			data, _ := os.ReadFile(log.Name())
+			t.Logf("%v log:\n%s", id, data)
+		}
+	})
+	return m
+}
+
+func call(method, addr, path, body string) (*http.Response, error) {
+	req, _ := http.NewRequest(method, "http://"+addr+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test")
+	client := &http.Client{Timeout: time.Second, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	return client.Do(req)
+}
+
+func memberStatus(m *testMember) (status map[string]interface{}) {
+	resp, err := call(http.MethodGet, m.http, "/cluster", "")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(&status)
+	return
+}
+
+// eventually - poll cond until it holds or timeout passes
+func eventually(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %v", what)
+}
+
+// TestClusterFailover - three server processes on one machine, leader is killed, survivors elect new one
+// which keeps accepting writes replicated to remaining follower
+func TestClusterFailover(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts server processes")
+	}
+	bin := filepath.Join(t.TempDir(), "server")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	m1 := startMember(t, bin, "m1", "")
+	eventually(t, 20*time.Second, "m1 to lead", func() bool { return memberStatus(m1)["state"] == "Leader" })
+	m2 := startMember(t, bin, "m2", m1.http)
+	m3 := startMember(t, bin, "m3", m1.http)
+	members := []*testMember{m1, m2, m3}
+	eventually(t, 30*time.Second, "members to join", func() bool {
+		peers, _ := memberStatus(m1)["peers"].([]interface{})
+		return len(peers) == 3 && memberStatus(m2)["state"] == "Follower" && memberStatus(m3)["state"] == "Follower"
+	})
+
+	m1.cmd.Process.Kill()
+	m1.cmd.Wait()
+	var leader, follower *testMember
+	eventually(t, 30*time.Second, "new leader", func() bool {
+		for _, m := range members[1:] {
+			if memberStatus(m)["state"] == "Leader" {
+				leader = m
+			} else {
+				follower = m
+			}
+		}
+		return leader != nil && follower != nil && leader != follower
+	})
+
+	// followers redirect writes to the leader found by its raft id
+	eventually(t, 10*time.Second, "redirect to new leader", func() bool {
+		resp, err := call(http.MethodPost, follower.http, "/namespaces", `{"name":"team"}`)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusTemporaryRedirect && strings.Contains(resp.Header.Get("Location"), leader.http)
+	})
+	eventually(t, 10*time.Second, "write on new leader", func() bool {
+		resp, err := call(http.MethodPost, leader.http, "/namespaces", `{"name":"team"}`)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict
+	})
+	eventually(t, 10*time.Second, "replicated namespace", func() bool {
+		resp, err := call(http.MethodGet, follower.http, "/namespaces", "")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		namespaces := []string{}
+		json.NewDecoder(resp.Body).Decode(&namespaces)
+		return fmt.Sprint(namespaces) == "[default team]"
+	})
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// storedConfig - version of config blob, every version is kept until config is deleted
+type storedConfig struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Version   int       `json:"version"`
+	Data      string    `json:"data,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+type configRequest struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+// configKey - current version of config
+func configKey(qualified string) string {
+	return "Config-" + qualified
+}
+
+func configVersionKey(qualified string, version int) string {
+	return fmt.Sprintf("ConfigVersion-%v-%v", qualified, version)
+}
+
+func currentConfig(qualified string) (c storedConfig, err error) {
+	err = kv.GetJSON(db, configKey(qualified), &c)
+	return
+}
+
+// resolveConfigs - current data of configs referenced by service, refs get delivered versions
+func resolveConfigs(service string, refs []kv.ConfigRef) (configs []*pb.Config, delivered []kv.ConfigRef) {
+	ns, _ := kv.SplitName(service)
+	for _, ref := range refs {
+		c, err := currentConfig(kv.QualifiedName(ns, ref.Name))
+		if err != nil {
+			log.Printf("Failed to resolve config %v of %v: %v", ref.Name, service, err)
+			continue
+		}
+		ref.Version = c.Version
+		delivered = append(delivered, ref)
+		configs = append(configs, &pb.Config{Name: ref.Name, Data: []byte(c.Data), Path: ref.Path, Version: int64(c.Version)})
+	}
+	return
+}
+
+// checkConfigRefs - error if service references missing config or mounts it wrong
+func checkConfigRefs(ns string, refs []kv.ConfigRef) error {
+	for _, ref := range refs {
+		if !kv.KeyExist(db, configKey(kv.QualifiedName(ns, ref.Name))) {
+			return fmt.Errorf("unknown config %v", ref.Name)
+		}
+		if !path.IsAbs(ref.Path) || path.Clean(ref.Path) == "/" || strings.ContainsAny(ref.Path, ": ") {
+			return fmt.Errorf("wrong path of config %v", ref.Name)
+		}
+	}
+	return nil
+}
+
+// configUsers - services of namespace referencing config
+func configUsers(ns, name string) (services []string) {
+	for _, s := range namespaceServices(ns) {
+		for _, ref := range serviceSpec(s).Configs {
+			if ref.Name == name {
+				services = append(services, s)
+			}
+		}
+	}
+	return
+}
+
+// staleConfigs - container got older version of some config than current one
+func staleConfigs(service string, rec kv.Container) bool {
+	ns, _ := kv.SplitName(service)
+	delivered := map[string]int{}
+	for _, ref := range rec.Configs {
+		delivered[ref.Name+" "+ref.Path] = ref.Version
+	}
+	for _, ref := range serviceSpec(service).Configs {
+		c, err := currentConfig(kv.QualifiedName(ns, ref.Name))
+		if err != nil {
+			continue
+		}
+		if v, ok := delivered[ref.Name+" "+ref.Path]; !ok || v != c.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// rolloutLoop - restart containers with stale configs one by one per service, next one waits until replacement runs
+func rolloutLoop() {
+	// services with restarts in progress, completion is reported once
+	rolling := map[string]bool{}
+	for {
+		time.Sleep(5 * time.Second)
+		if !isLeader() {
+			continue
+		}
+		reported := reportedContainers()
+		for _, s := range kv.GetList(db, "Services") {
+			if len(serviceSpec(s).Configs) == 0 {
+				continue
+			}
+			containers := kv.GetList(db, kv.ServiceKey(s))
+			busy := false
+			for _, c := range containers {
+				if r, ok := reported[c]; restarting(c) || !ok || r.GetState() != "running" {
+					busy = true
+					break
+				}
+			}
+			if busy {
+				continue
+			}
+			stale := []string{}
+			for _, c := range containers {
+				rec := kv.Container{}
+				if err := kv.GetJSON(db, c, &rec); err == nil && staleConfigs(s, rec) {
+					stale = append(stale, c)
+				}
+			}
+			if len(stale) == 0 {
+				if rolling[s] {
+					delete(rolling, s)
+					recordEvent("rollout-completed", s, "", fmt.Sprintf("all %v replicas run updated configs", len(containers)))
+				}
+				continue
+			}
+			rolling[s] = true
+			log.Printf("Rollout: restarting %v of %v with updated configs", stale[0], s)
+			markRestart(stale[0])
+			recordEvent("rollout-progress", s, "", fmt.Sprintf("restarting %v, %v of %v replicas left", stale[0], len(stale), len(containers)))
+		}
+	}
+}
+
+func configCreate(c echo.Context) error {
+	return createConfig(c, kv.DefaultNamespace)
+}
+
+func nsConfigCreate(c echo.Context) error {
+	return createConfig(c, c.Param("ns"))
+}
+
+// createConfig - store new version of config, services using it are restarted by rollout
+func createConfig(c echo.Context, ns string) error {
+	req := configRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	name := kv.QualifiedName(ns, req.Name)
+	c.Set(auditTarget, name)
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	if !validName.MatchString(req.Name) {
+		return c.String(http.StatusBadRequest, "Wrong config name")
+	}
+	cfg := storedConfig{Name: req.Name, Namespace: ns, Version: 1, Created: time.Now()}
+	if old, err := currentConfig(name); err == nil {
+		if old.Data == req.Data {
+			return c.JSON(http.StatusOK, old)
+		}
+		cfg.Version, cfg.Created = old.Version+1, old.Created
+	}
+	cfg.Data, cfg.Updated = req.Data, time.Now()
+	if err := kv.PutJSON(db, configVersionKey(name, cfg.Version), cfg); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if err := kv.PutJSON(db, configKey(name), cfg); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	log.Printf("Config %v stored, version %v", name, cfg.Version)
+	return c.JSON(http.StatusOK, cfg)
+}
+
+func configsList(c echo.Context) error {
+	return listConfigs(c, kv.DefaultNamespace)
+}
+
+func nsConfigsList(c echo.Context) error {
+	return listConfigs(c, c.Param("ns"))
+}
+
+// listConfigs - current versions without data
+func listConfigs(c echo.Context, ns string) error {
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	configs := []storedConfig{}
+	for _, k := range kv.KeysList(db, configKey("")) {
+		cfg := storedConfig{}
+		if err := kv.GetJSON(db, k, &cfg); err != nil || cfg.Namespace != ns {
+			continue
+		}
+		cfg.Data = ""
+		configs = append(configs, cfg)
+	}
+	return c.JSON(http.StatusOK, configs)
+}
+
+func configGet(c echo.Context) error {
+	return getConfig(c, kv.DefaultNamespace)
+}
+
+func nsConfigGet(c echo.Context) error {
+	return getConfig(c, c.Param("ns"))
+}
+
+// getConfig - current version with data, ?version= returns older one
+func getConfig(c echo.Context, ns string) error {
+	name := kv.QualifiedName(ns, c.Param("name"))
+	cfg, err := currentConfig(name)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Unknown config")
+	}
+	if v := c.QueryParam("version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Wrong version")
+		}
+		if err := kv.GetJSON(db, configVersionKey(name, version), &cfg); err != nil {
+			return c.String(http.StatusNotFound, "Unknown version")
+		}
+	}
+	return c.JSON(http.StatusOK, cfg)
+}
+
+func configDelete(c echo.Context) error {
+	return deleteConfig(c, kv.DefaultNamespace)
+}
+
+func nsConfigDelete(c echo.Context) error {
+	return deleteConfig(c, c.Param("ns"))
+}
+
+func deleteConfig(c echo.Context, ns string) error {
+	name := kv.QualifiedName(ns, c.Param("name"))
+	c.Set(auditTarget, name)
+	cfg, err := currentConfig(name)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Unknown config")
+	}
+	if users := configUsers(ns, c.Param("name")); len(users) > 0 {
+		return c.String(http.StatusConflict, fmt.Sprintf("Config is used by %v", strings.Join(users, ", ")))
+	}
+	for v := 1; v <= cfg.Version; v++ {
+		kv.DeleteKV(db, configVersionKey(name, v))
+	}
+	kv.DeleteKV(db, configKey(name))
+	log.Printf("Config %v deleted", name)
+	return c.NoContent(http.StatusNoContent)
+}
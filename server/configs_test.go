@@ -0,0 +1,30 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"testing"
+)
+
+func TestStaleConfigs(t *testing.T) {
+	testStore(t)
+	kv.PutJSON(db, configKey("team.nginx"), storedConfig{Name: "nginx", Namespace: "team", Version: 2})
+	kv.PutJSON(db, specKey("team.web"), svcConfig{Name: "web", Configs: []kv.ConfigRef{
+		{Name: "nginx", Path: "/etc/nginx/nginx.conf"},
+		// removed config doesn't make containers stale
+		{Name: "gone", Path: "/etc/gone"},
+	}})
+	for _, c := range []struct {
+		name    string
+		configs []kv.ConfigRef
+		stale   bool
+	}{
+		{"current version", []kv.ConfigRef{{Name: "nginx", Path: "/etc/nginx/nginx.conf", Version: 2}}, false},
+		{"older version", []kv.ConfigRef{{Name: "nginx", Path: "/etc/nginx/nginx.conf", Version: 1}}, true},
+		{"other path", []kv.ConfigRef{{Name: "nginx", Path: "/etc/nginx.conf", Version: 2}}, true},
+		{"not delivered", nil, true},
+	} {
+		if got := staleConfigs("team.web", kv.Container{Configs: c.configs}); got != c.stale {
+			t.Errorf("%v: staleConfigs = %v, want %v", c.name, got, c.stale)
+		}
+	}
+}
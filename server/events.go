@@ -0,0 +1,277 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// event - cluster event, kept for -events-ttl
+type event struct {
+	ID      string    `json:"id"`
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Service string    `json:"service,omitempty"`
+	Node    string    `json:"node,omitempty"`
+	Message string    `json:"message"`
+}
+
+// bus - channels of connected event streams
+var bus = struct {
+	sync.Mutex
+	subscribers map[chan event]bool
+}{subscribers: map[chan event]bool{}}
+
+func subscribe() chan event {
+	bus.Lock()
+	defer bus.Unlock()
+	ch := make(chan event, 64)
+	bus.subscribers[ch] = true
+	return ch
+}
+
+func unsubscribe(ch chan event) {
+	bus.Lock()
+	defer bus.Unlock()
+	delete(bus.subscribers, ch)
+}
+
+// publish - send event to streams, slow ones miss it and may catch up with Last-Event-ID
+func publish(e event) {
+	bus.Lock()
+	defer bus.Unlock()
+	for ch := range bus.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// eventSeqKey - sequence number of last event, stream clients resume after it
+const eventSeqKey = "EventSeq"
+
+// recording - events are committed and published in sequence order
+var recording sync.Mutex
+
+func eventKey(seq uint64) string {
+	return fmt.Sprintf("Event-%020d", seq)
+}
+
+// recordEvent - append event with next sequence number and publish it
+func recordEvent(typ, service, node, message string) {
+	recording.Lock()
+	defer recording.Unlock()
+	e := event{Time: time.Now(), Type: typ, Service: service, Node: node, Message: message}
+	err := kv.Update(db, func(b *kv.Batch) error {
+		seq := uint64(0)
+		if b.Has(eventSeqKey) {
+			if err := b.GetJSON(eventSeqKey, &seq); err != nil {
+				return err
+			}
+		}
+		e.Seq = seq + 1
+		e.ID = eventKey(e.Seq)
+		b.PutJSON(eventSeqKey, e.Seq)
+		return b.PutJSON(e.ID, e)
+	})
+	if err != nil {
+		log.Printf("Failed to record event %v of %v: %v", typ, service, err)
+		return
+	}
+	publish(e)
+}
+
+// lastEventSeq - sequence number of last recorded event
+func lastEventSeq() (seq uint64) {
+	if kv.KeyExist(db, eventSeqKey) {
+		kv.GetJSON(db, eventSeqKey, &seq)
+	}
+	return
+}
+
+// eventKeys - keys of numbered events in sequence order, zero padding keeps key order numeric
+func eventKeys() (keys []string) {
+	for _, k := range kv.KeysList(db, "Event-") {
+		if len(k) == len(eventKey(0)) {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+func loadEvent(key string) (e event, ok bool) {
+	return e, kv.GetJSON(db, key, &e) == nil
+}
+
+// storedEvents - events recorded after sequence number after and time since in sequence order, start is found
+// by binary search over keys and only later events are loaded
+func storedEvents(since time.Time, after uint64) (events []event) {
+	keys := eventKeys()
+	keys = keys[sort.SearchStrings(keys, eventKey(after+1)):]
+	if !since.IsZero() {
+		keys = keys[sort.Search(len(keys), func(i int) bool {
+			e, ok := loadEvent(keys[i])
+			return !ok || e.Time.After(since)
+		}):]
+	}
+	for _, k := range keys {
+		if e, ok := loadEvent(k); ok {
+			events = append(events, e)
+		}
+	}
+	return
+}
+
+// expiredEvents - keys of events older than ttl, events recorded before sequence numbers included
+func expiredEvents(ttl time.Duration) (keys []string) {
+	for _, k := range kv.KeysList(db, "Event-") {
+		if len(k) == len(eventKey(0)) {
+			continue
+		}
+		if e, ok := loadEvent(k); ok && time.Since(e.Time) > ttl {
+			keys = append(keys, k)
+		}
+	}
+	numbered := eventKeys()
+	n := sort.Search(len(numbered), func(i int) bool {
+		e, ok := loadEvent(numbered[i])
+		return !ok || time.Since(e.Time) <= ttl
+	})
+	return append(keys, numbered[:n]...)
+}
+
+// eventsFilter - ?since= (RFC3339), ?type= (comma separated) and ?service= (qualified name) of events request
+func eventsFilter(c echo.Context) (since time.Time, match func(e event) bool, err error) {
+	if v := c.QueryParam("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			return
+		}
+	}
+	types := map[string]bool{}
+	for _, t := range strings.Split(c.QueryParam("type"), ",") {
+		if t != "" {
+			types[t] = true
+		}
+	}
+	service := c.QueryParam("service")
+	match = func(e event) bool {
+		return (len(types) == 0 || types[e.Type]) && (service == "" || e.Service == service)
+	}
+	return
+}
+
+// eventsGet - stream with Accept: text/event-stream, otherwise list of stored events
+func eventsGet(c echo.Context) error {
+	if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream") {
+		return eventsStream(c)
+	}
+	return eventsList(c)
+}
+
+// eventsList - stored events matching filter
+func eventsList(c echo.Context) error {
+	since, match, err := eventsFilter(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Wrong since, RFC3339 expected")
+	}
+	events := []event{}
+	for _, e := range storedEvents(since, 0) {
+		if match(e) {
+			events = append(events, e)
+		}
+	}
+	return c.JSON(http.StatusOK, events)
+}
+
+// eventsStream - stored events after ?since= or Last-Event-ID (sequence number), then live ones as Server-Sent Events
+func eventsStream(c echo.Context) error {
+	// events are produced by leader, followers would stream nothing
+	if !isLeader() {
+		leader, err := member.Leader()
+		if err != nil {
+			return c.String(http.StatusServiceUnavailable, "No cluster leader")
+		}
+		return c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%v://%v%v", httpScheme(), leader.HTTP, c.Request().RequestURI))
+	}
+	since, match, err := eventsFilter(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Wrong since, RFC3339 expected")
+	}
+	last := uint64(0)
+	resume := c.Request().Header.Get("Last-Event-ID")
+	if resume != "" {
+		if last, err = strconv.ParseUint(resume, 10, 64); err != nil {
+			return c.String(http.StatusBadRequest, "Wrong Last-Event-ID, sequence number expected")
+		}
+	}
+
+	// subscribe before replay, live events already replayed are skipped by sequence number
+	ch := subscribe()
+	defer unsubscribe(ch)
+	head := lastEventSeq()
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if resume != "" || !since.IsZero() {
+		last = replayEvents(w, since, last, match)
+	}
+	if last < head {
+		last = head
+	}
+	w.Flush()
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case e := <-ch:
+			switch {
+			case e.Seq <= last:
+			case e.Seq > last+1:
+				// events missed by full subscriber channel are read from store
+				last = replayEvents(w, time.Time{}, last, match)
+			default:
+				if match(e) {
+					writeEvent(w, e)
+				}
+				last = e.Seq
+			}
+			w.Flush()
+		case <-ping.C:
+			// comment keeps proxies from closing idle stream
+			fmt.Fprint(w, ": ping\n\n")
+			w.Flush()
+		}
+	}
+}
+
+// replayEvents - write stored events after last matching filter, returns sequence number of last stored one
+func replayEvents(w io.Writer, since time.Time, last uint64, match func(e event) bool) uint64 {
+	for _, e := range storedEvents(since, last) {
+		if match(e) {
+			writeEvent(w, e)
+		}
+		last = e.Seq
+	}
+	return last
+}
+
+func writeEvent(w io.Writer, e event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", e.Seq, e.Type, data)
+}
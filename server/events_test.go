@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	kv "dockerator/kvstore"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestStoredEvents(t *testing.T) {
+	testStore(t)
+	// event recorded before sequence numbers
+	kv.PutJSON(db, "Event-legacy", event{ID: "Event-legacy", Time: time.Now().Add(-2 * time.Hour), Type: "node-added"})
+	for i := 0; i < 12; i++ {
+		recordEvent("service-created", "web", "", "created")
+	}
+	events := storedEvents(time.Time{}, 9)
+	if len(events) != 3 || events[0].Seq != 10 || events[2].Seq != 12 {
+		t.Fatalf("events after 9 = %+v", events)
+	}
+	old := event{}
+	kv.GetJSON(db, eventKey(1), &old)
+	old.Time = time.Now().Add(-time.Hour)
+	kv.PutJSON(db, eventKey(1), old)
+	if events := storedEvents(time.Now().Add(-time.Minute), 0); len(events) != 11 || events[0].Seq != 2 {
+		t.Fatalf("events since minute = %+v", events)
+	}
+	expired := expiredEvents(30 * time.Minute)
+	if strings.Join(expired, ",") != "Event-legacy,"+eventKey(1) {
+		t.Fatalf("expired = %v", expired)
+	}
+}
+
+// eventsClient - ids of events streamed by GET /events with header Last-Event-ID
+func eventsClient(t *testing.T, srv *httptest.Server, query, resume string) <-chan string {
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events"+query, nil)
+	req.Header.Set(echo.HeaderAccept, "text/event-stream")
+	if resume != "" {
+		req.Header.Set("Last-Event-ID", resume)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %v", resp.StatusCode)
+	}
+	ids := make(chan string, 16)
+	go func() {
+		s := bufio.NewScanner(resp.Body)
+		for s.Scan() {
+			if id, ok := strings.CutPrefix(s.Text(), "id: "); ok {
+				ids <- id
+			}
+		}
+		close(ids)
+	}()
+	return ids
+}
+
+func expectIDs(t *testing.T, ids <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case id := <-ids:
+			if id != w {
+				t.Fatalf("event %v, want %v", id, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %v not streamed", w)
+		}
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	testStore(t)
+	e := echo.New()
+	e.GET("/events", eventsGet)
+	srv := httptest.NewServer(e)
+	// cleanups run in reverse, streams are closed before server waits for them
+	t.Cleanup(srv.Close)
+
+	recordEvent("service-scaled", "web", "", "1")
+	recordEvent("service-scaled", "api", "", "2")
+	recordEvent("node-down", "", "n1", "3")
+	recordEvent("service-scaled", "web", "", "4")
+	recordEvent("task-failed", "web", "n1", "5")
+
+	// resume replays stored events after Last-Event-ID matching filter
+	ids := eventsClient(t, srv, "?type=service-scaled,task-failed&service=web", "1")
+	expectIDs(t, ids, "4", "5")
+	all := eventsClient(t, srv, "", "")
+
+	recordEvent("service-scaled", "api", "", "6")
+	recordEvent("task-failed", "web", "n2", "7")
+	expectIDs(t, ids, "7")
+	expectIDs(t, all, "6", "7")
+
+	// event missed by subscribers is read from store on next one
+	kv.PutJSON(db, eventKey(8), event{ID: eventKey(8), Seq: 8, Time: time.Now(), Type: "service-scaled", Service: "web"})
+	kv.PutJSON(db, eventSeqKey, 8)
+	recordEvent("node-down", "", "n2", "9")
+	expectIDs(t, ids, "8")
+	expectIDs(t, all, "8", "9")
+}
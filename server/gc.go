@@ -0,0 +1,252 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type report struct {
+	managed bool
+	seen    time.Time
+}
+
+// reports - containers reported by agents and dispatched by this leader
+var reports = struct {
+	sync.Mutex
+	nodes      map[string]map[string]report
+	dispatched map[string]time.Time
+	restarts   map[string]bool
+}{
+	nodes:      map[string]map[string]report{},
+	dispatched: map[string]time.Time{},
+	restarts:   map[string]bool{},
+}
+
+func pendingDeleteKey(container string) string {
+	return "PendingDelete-" + container
+}
+
+func recordReport(node, container string, managed bool) {
+	reports.Lock()
+	defer reports.Unlock()
+	if reports.nodes[node] == nil {
+		reports.nodes[node] = map[string]report{}
+	}
+	reports.nodes[node][container] = report{managed, time.Now()}
+}
+
+// unmanagedContainers - containers not created by dockerator, reported by agents for visibility
+func unmanagedContainers(node string) (containers []string) {
+	reports.Lock()
+	defer reports.Unlock()
+	for c, r := range reports.nodes[node] {
+		if !r.managed {
+			containers = append(containers, c)
+		}
+	}
+	sort.Strings(containers)
+	return
+}
+
+func recordDispatch(container string) {
+	reports.Lock()
+	defer reports.Unlock()
+	reports.dispatched[container] = time.Now()
+}
+
+// markDelete - ask node reporting container to delete it, mark survives leader change
+// and is kept until container is no longer reported
+func markDelete(container string) {
+	if err := kv.PutJSON(db, pendingDeleteKey(container), time.Now()); err != nil {
+		log.Printf("Failed to mark %v for deletion: %v", container, err)
+	}
+}
+
+// pendingDelete - check if container is marked for deletion
+func pendingDelete(container string) bool {
+	return kv.KeyExist(db, pendingDeleteKey(container))
+}
+
+// markRestart - ask node reporting container to recreate it with current spec
+func markRestart(container string) {
+	reports.Lock()
+	defer reports.Unlock()
+	reports.restarts[container] = true
+}
+
+// pendingRestart - check and clear container marked for restart by rollout
+func pendingRestart(container string) bool {
+	reports.Lock()
+	defer reports.Unlock()
+	if reports.restarts[container] {
+		delete(reports.restarts, container)
+		return true
+	}
+	return false
+}
+
+func restarting(container string) bool {
+	reports.Lock()
+	defer reports.Unlock()
+	return reports.restarts[container]
+}
+
+// recent - container reported by node or dispatched within grace period
+func recent(node, container string, grace time.Duration) bool {
+	reports.Lock()
+	defer reports.Unlock()
+	if r, ok := reports.nodes[node][container]; ok && time.Since(r.seen) < grace {
+		return true
+	}
+	return time.Since(reports.dispatched[container]) < grace
+}
+
+func gcLoop() {
+	leaderSince := time.Time{}
+	for {
+		time.Sleep(*gcInterval)
+		if !isLeader() {
+			leaderSince = time.Time{}
+			continue
+		}
+		// reports are kept in memory, wait a grace period after election to collect them
+		if leaderSince.IsZero() {
+			leaderSince = time.Now()
+		}
+		if time.Since(leaderSince) < *gcGrace {
+			continue
+		}
+		collectGarbage(*gcGrace)
+	}
+}
+
+// collectGarbage - reconcile agent reports with stored state
+func collectGarbage(grace time.Duration) {
+	nodes := kv.GetList(db, "Nodes")
+	for _, n := range nodes {
+		if nodeStatus(n) != "up" {
+			continue
+		}
+		for _, c := range kv.GetList(db, kv.NodeKey(n)) {
+			if !recent(n, c, grace) {
+				log.Printf("GC: %v is not reported by %v, removing from state", c, n)
+				removeContainer(n, c)
+			}
+		}
+	}
+
+	for _, s := range kv.GetList(db, "Services") {
+		containers := kv.GetList(db, kv.ServiceKey(s))
+		for _, c := range containers {
+			if !kv.KeyExist(db, c) {
+				log.Printf("GC: removing dangling %v from service %v", c, s)
+				kv.EjectKV(db, kv.ServiceKey(s), c)
+			}
+		}
+		for _, c := range serviceRecords(s) {
+			if !kv.InList(db, kv.ServiceKey(s), c) {
+				log.Printf("GC: removing orphaned record %v", c)
+				kv.DeleteKV(db, c)
+			}
+		}
+	}
+
+	for _, prefix := range []string{leaseKey(""), nodeStateKey(""), nodeIPKey(""), inventoryKey("")} {
+		for _, k := range kv.KeysList(db, prefix) {
+			if !contains(nodes, strings.TrimPrefix(k, prefix)) {
+				log.Printf("GC: removing %v of unknown node", k)
+				kv.DeleteKV(db, k)
+			}
+		}
+	}
+
+	for _, prefix := range []string{taskStatusKey(""), replacementKey("")} {
+		for _, k := range kv.KeysList(db, prefix) {
+			if !kv.KeyExist(db, strings.TrimPrefix(k, prefix)) {
+				kv.DeleteKV(db, k)
+			}
+		}
+	}
+
+	for _, k := range expiredEvents(*eventsTTL) {
+		kv.DeleteKV(db, k)
+	}
+
+	for _, id := range expiredTokens() {
+		log.Printf("GC: removing expired join token %v", id)
+		kv.DeleteKV(db, tokenKey(id))
+	}
+
+	unknown, reported := pruneReports(grace)
+	for _, c := range unknown {
+		if *gcDeleteUnknown {
+			markDelete(c)
+		}
+	}
+	for _, k := range kv.KeysList(db, pendingDeleteKey("")) {
+		if c := strings.TrimPrefix(k, pendingDeleteKey("")); !reported[c] {
+			log.Printf("GC: %v is deleted", c)
+			kv.DeleteKV(db, k)
+		}
+	}
+}
+
+// pruneReports - drop reports older than grace, returns unknown managed containers and all reported ones
+func pruneReports(grace time.Duration) (unknown []string, reported map[string]bool) {
+	reports.Lock()
+	defer reports.Unlock()
+	reported = map[string]bool{}
+	for n, containers := range reports.nodes {
+		for c, r := range containers {
+			if time.Since(r.seen) >= grace {
+				delete(containers, c)
+				continue
+			}
+			reported[c] = true
+			if !r.managed || kv.KeyExist(db, c) {
+				continue
+			}
+			if *gcDeleteUnknown {
+				log.Printf("GC: deleting unknown managed container %v on %v", c, n)
+			} else {
+				log.Printf("GC: unknown managed container %v on %v", c, n)
+			}
+			unknown = append(unknown, c)
+		}
+	}
+	for c, t := range reports.dispatched {
+		if time.Since(t) >= grace {
+			delete(reports.dispatched, c)
+		}
+	}
+	return
+}
+
+func removeContainer(node, container string) {
+	kv.EjectKV(db, kv.NodeKey(node), container)
+	kv.EjectKV(db, kv.ServiceKey(kv.ServiceName(container)), container)
+	kv.DeleteKV(db, container)
+}
+
+// serviceRecords - container records of service, "<service>-<xid>" keys
+func serviceRecords(service string) (records []string) {
+	for _, k := range kv.KeysList(db, service+"-") {
+		if len(k) == len(service)+21 {
+			records = append(records, k)
+		}
+	}
+	return
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
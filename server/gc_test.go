@@ -0,0 +1,87 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"testing"
+	"time"
+)
+
+// testReports - empty in-memory agent reports, restored after test
+func testReports(t *testing.T) {
+	reports.Lock()
+	nodes, dispatched := reports.nodes, reports.dispatched
+	reports.nodes, reports.dispatched = map[string]map[string]report{}, map[string]time.Time{}
+	reports.Unlock()
+	t.Cleanup(func() {
+		reports.Lock()
+		reports.nodes, reports.dispatched = nodes, dispatched
+		reports.Unlock()
+	})
+}
+
+func TestCollectGarbage(t *testing.T) {
+	testStore(t)
+	testReports(t)
+	*gcDeleteUnknown = true
+	defer func() { *gcDeleteUnknown = false }()
+	grace := time.Minute
+	reported, lost, dangling, orphaned, unknown, gone := nameWithSuffix("web"), nameWithSuffix("web"), nameWithSuffix("web"),
+		nameWithSuffix("web"), nameWithSuffix("web"), nameWithSuffix("web")
+
+	kv.PutJSON(db, "Nodes", []string{"n1"})
+	kv.PutKV(db, nodeStateKey("n1"), "up")
+	kv.PutJSON(db, kv.NodeKey("n1"), []string{reported, lost})
+	kv.PutJSON(db, "Services", []string{"web"})
+	kv.PutJSON(db, kv.ServiceKey("web"), []string{reported, lost, dangling})
+	for _, c := range []string{reported, lost, orphaned} {
+		kv.PutJSON(db, c, kv.Container{Image: "nginx", Replicas: 1})
+	}
+	// records of removed node
+	kv.PutKV(db, nodeStateKey("n9"), "down")
+	kv.PutKV(db, nodeIPKey("n9"), "10.0.0.9")
+	markDelete(gone)
+
+	recordReport("n1", reported, true)
+	recordReport("n1", unknown, true)
+	recordReport("n1", "redis", false)
+	reports.nodes["n1"]["stale"] = report{true, time.Now().Add(-2 * grace)}
+
+	collectGarbage(grace)
+
+	if got := kv.GetList(db, kv.NodeKey("n1")); len(got) != 1 || got[0] != reported {
+		t.Errorf("containers of n1 = %v", got)
+	}
+	if got := kv.GetList(db, kv.ServiceKey("web")); len(got) != 1 || got[0] != reported {
+		t.Errorf("containers of web = %v", got)
+	}
+	if !kv.KeyExist(db, reported) || kv.KeyExist(db, lost) || kv.KeyExist(db, orphaned) {
+		t.Error("container records not reconciled")
+	}
+	if kv.KeyExist(db, nodeStateKey("n9")) || kv.KeyExist(db, nodeIPKey("n9")) || !kv.KeyExist(db, nodeStateKey("n1")) {
+		t.Error("records of unknown node not removed")
+	}
+	if !pendingDelete(unknown) || pendingDelete("redis") || pendingDelete(reported) {
+		t.Error("unknown managed container is not the only one marked")
+	}
+	if pendingDelete(gone) {
+		t.Error("mark of container no longer reported kept")
+	}
+	if _, ok := reports.nodes["n1"]["stale"]; ok {
+		t.Error("stale report kept")
+	}
+}
+
+func TestPruneReportsKeepsUnknownWithoutDelete(t *testing.T) {
+	testStore(t)
+	testReports(t)
+	c := nameWithSuffix("web")
+	recordReport("n1", c, true)
+	collectGarbage(time.Minute)
+	if pendingDelete(c) {
+		t.Fatal("unknown container marked without -gc-delete-unknown")
+	}
+	unknown, reported := pruneReports(time.Minute)
+	if len(unknown) != 1 || unknown[0] != c || !reported[c] {
+		t.Fatalf("prune = %v, %v", unknown, reported)
+	}
+}
@@ -5,53 +5,112 @@ import (
 	"dockerator/docker"
 	pb "dockerator/dockerator"
 	kv "dockerator/kvstore"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/rs/xid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
-const (
-	port = ":50051"
+var (
+	httpAddr          = flag.String("http", ":8080", "REST API listen address")
+	grpcAddr          = flag.String("grpc", ":50051", "gRPC listen address")
+	storeBackend      = flag.String("store", "bitcask", "state store backend: bitcask|etcd")
+	dbPath            = flag.String("db", "/tmp/db", "path to state db")
+	etcdEndpoints     = flag.String("etcd", "127.0.0.1:2379", "comma separated etcd endpoints for etcd store")
+	etcdEmbed         = flag.String("etcd-embed", "", "run embedded single member etcd with data in this dir")
+	migrateDryRun     = flag.Bool("migrate-dry-run", false, "print pending schema migrations and exit")
+	nodeID            = flag.String("id", "", "cluster member ID")
+	raftAddr          = flag.String("raft", "", "raft bind address, enables clustering (e.g. 127.0.0.1:7000)")
+	raftDir           = flag.String("raft-dir", "/tmp/raft", "path to raft log and snapshots")
+	bootstrap         = flag.Bool("bootstrap", false, "bootstrap new cluster with this member")
+	joinAddr          = flag.String("join", "", "REST API address of cluster member to join")
+	nodeTTL           = flag.Duration("node-ttl", 15*time.Second, "node is marked down if no heartbeat received within ttl")
+	gcInterval        = flag.Duration("gc-interval", time.Minute, "interval of orphaned state garbage collection")
+	gcGrace           = flag.Duration("gc-grace", 3*time.Minute, "containers not reported by agents for this long are removed from state, must exceed agents resync interval")
+	gcDeleteUnknown   = flag.Bool("gc-delete-unknown", false, "delete managed containers unknown to server")
+	advertiseHost     = flag.String("advertise", "127.0.0.1", "host advertised to other members for HTTP and gRPC")
+	staticJoinToken   = flag.String("join-token", "", "static node join token accepted in addition to generated ones")
+	joinTokenTTL      = flag.Duration("join-token-ttl", 24*time.Hour, "default ttl of generated node join tokens")
+	nodeCertTTL       = flag.Duration("node-cert-ttl", 30*24*time.Hour, "lifetime of node and server certificates issued by cluster CA")
+	tlsSAN            = flag.String("tls-san", "172.17.0.1", "extra comma separated hosts of gRPC server certificate")
+	adminToken        = flag.String("admin-token", "", "static REST API admin token, also used to join cluster")
+	httpTLS           = flag.Bool("http-tls", false, "serve REST API over TLS with certificate issued by cluster CA")
+	httpCert          = flag.String("http-cert", "", "REST API TLS certificate file")
+	httpKey           = flag.String("http-key", "", "REST API TLS key file")
+	joinCA            = flag.String("join-ca", "", "CA certificate to verify REST API of member to join")
+	secretsKey        = flag.String("secrets-key", "", "file with base64 AES-256 key encrypting CA key and secrets at rest, required, same on all members")
+	autoscaleInterval = flag.Duration("autoscale-interval", 30*time.Second, "interval of autoscaling policies evaluation")
+	eventsTTL         = flag.Duration("events-ttl", 24*time.Hour, "cluster events older than ttl are removed")
 )
 
-var db = kv.InitDB("/tmp/db")
+var db kv.Store
 var taskQueue = make(chan string, 100)
 
+// queuedTasks - task keys waiting in taskQueue, kept in db until dispatched
+var queuedTasks = struct {
+	sync.Mutex
+	keys map[string]bool
+}{keys: map[string]bool{}}
+
 type server struct{}
 
 type svcConfig struct {
-	Name     string `json:"name"`
-	Image    string `json:"image"`
-	Replicas int    `json:"rs"`
+	Name      string           `json:"name"`
+	Image     string           `json:"image"`
+	Replicas  int              `json:"rs"`
+	CPUs      float64          `json:"cpus,omitempty"`
+	Memory    int64            `json:"memory,omitempty"`
+	Secrets   []kv.SecretRef   `json:"secrets,omitempty"`
+	Configs   []kv.ConfigRef   `json:"configs,omitempty"`
+	Registry  string           `json:"registry,omitempty"`
+	Pull      string           `json:"pull_policy,omitempty"`
+	Digest    string           `json:"digest,omitempty"`
+	Autoscale *autoscalePolicy `json:"autoscale,omitempty"`
 }
 
 type node struct {
-	Name   string `json:"name"`
-	IP     string `json:"ip"`
-	Uptime string `json:"uptime"`
+	Name      string        `json:"name"`
+	IP        string        `json:"ip"`
+	Uptime    string        `json:"uptime"`
+	Status    string        `json:"status"`
+	Unmanaged []string      `json:"unmanaged,omitempty"`
+	Stats     *pb.NodeStats `json:"stats,omitempty"`
+	Reported  int64         `json:"reported,omitempty"`
 }
 
 type service struct {
 	Name       string      `json:"name"`
+	Namespace  string      `json:"namespace"`
 	Replicas   int         `json:"rs"`
 	Containers []container `json:"containers"`
 }
 
 type container struct {
-	Name  string `json:"name"`
-	Image string `json:"image"`
-	Node  string `json:"node"`
+	Name   string      `json:"name"`
+	Image  string      `json:"image"`
+	Node   string      `json:"node"`
+	State  string      `json:"state,omitempty"`
+	Health string      `json:"health,omitempty"`
+	Task   *taskStatus `json:"task,omitempty"`
 	// Uptime string `json:"uptime"`
 }
 
+type reportedContainer struct {
+	node string
+	*pb.ContainerStatus
+}
+
 type stateResponse struct {
 	Nodes    []node    `json:"nodes"`
 	Services []service `json:"services"`
@@ -62,59 +121,107 @@ func hello(c echo.Context) error {
 }
 
 func svc(c echo.Context) error {
-	service := svcConfig{}
-	err := c.Bind(&service)
-	if err != nil {
-		log.Printf("Failed to decode json: %v", err)
-		launchService(service.Name, service.Image, service.Replicas)
-		return c.String(http.StatusInternalServerError, "Wrong JSON format")
-	}
-
-	return c.JSON(http.StatusOK, service)
+	return createService(c, kv.DefaultNamespace)
 }
 
 func state(c echo.Context) error {
 	nodes := []node{}
-	services := []service{}
 	nodesMap := docker.GetNodeMap()
 
-	allNodes, _ := kv.GetKV(db, "Nodes")
-	for _, n := range strings.Split(allNodes, " ") {
-		name := nodesMap[n]
-		node := node{name, docker.GetContainerIP(name), docker.GetContainerUptime(name)}
+	for _, n := range kv.GetList(db, "Nodes") {
+		inventory := nodeInventory(n)
+		node := node{Name: n, IP: nodeIP(n), Status: nodeStatus(n), Unmanaged: unmanagedContainers(n),
+			Stats: inventory.GetStats(), Reported: inventory.GetTimestamp()}
+		// dind nodes on local daemon
+		if name, ok := nodesMap[node.IP]; ok {
+			node.Uptime = docker.GetContainerUptime(name)
+			if n == node.IP {
+				node.Name = name
+			}
+		}
 		nodes = append(nodes, node)
 	}
 
-	allServices, _ := kv.GetKV(db, "Services")
-	for _, s := range strings.Split(allServices, " ") {
+	svcNames := kv.GetList(db, "Services")
+	if ns := c.QueryParam("namespace"); ns != "" {
+		svcNames = namespaceServices(ns)
+	}
+	resp := stateResponse{nodes, servicesState(svcNames, reportedContainers())}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// reportedContainers - containers as seen in last status reports of nodes
+func reportedContainers() map[string]reportedContainer {
+	reported := map[string]reportedContainer{}
+	for _, n := range kv.GetList(db, "Nodes") {
+		for _, c := range nodeInventory(n).GetContainers() {
+			reported[c.GetName()] = reportedContainer{n, c}
+		}
+	}
+	return reported
+}
+
+func servicesState(svcNames []string, reported map[string]reportedContainer) []service {
+	services := []service{}
+	nodesMap := docker.GetNodeMap()
+	for _, s := range svcNames {
 		rs := kv.CountRS(db, s)
 		containers := []container{}
-		containersList, _ := kv.GetKV(db, s)
-		for _, c := range strings.Split(containersList, " ") {
-			i, _ := kv.GetKV(db, c)
-			image := strings.Split(i, " ")[0]
+		for _, c := range kv.GetList(db, kv.ServiceKey(s)) {
+			rec := kv.Container{}
+			kv.GetJSON(db, c, &rec)
 			node := ""
 			for k, v := range nodesMap {
-				conts, _ := kv.GetKV(db, k)
-				if strings.Contains(conts, c) {
+				if kv.InList(db, kv.NodeKey(k), c) {
 					node = v
 				}
 			}
-			container := container{c, image, node}
+			container := container{Name: c, Image: rec.Image, Node: node, Task: containerTaskStatus(c)}
+			if r, ok := reported[c]; ok {
+				container.Node, container.State, container.Health = r.node, r.GetState(), r.GetHealth()
+			}
 			containers = append(containers, container)
 		}
-		service := service{s, rs, containers}
-		services = append(services, service)
+		ns, name := kv.SplitName(s)
+		services = append(services, service{name, ns, rs, containers})
 	}
-	resp := stateResponse{nodes, services}
-	return c.JSON(http.StatusOK, resp)
+	return services
 }
 
 func main() {
+	flag.Parse()
+	db = openStore()
+	defer stopEmbeddedEtcd()
 	defer db.Close()
+	if *migrateDryRun {
+		changes, err := kv.Migrate(db, true)
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		if err != nil {
+			log.Fatalf("migration failed: %v", err)
+		}
+		return
+	}
+	if store, ok := db.(*kv.EtcdStore); ok {
+		startEtcdCluster(store)
+		defer member.Shutdown()
+	} else if *raftAddr != "" {
+		startCluster()
+		defer member.Shutdown()
+	}
+	migrateState()
+	// CA key is sealed with secrets key
+	initSecrets()
+	initCA()
+	initAuth()
+	go certRotationLoop()
 	go grpcServerStart()
 	go taskToQueueLoop()
 	go nodesCheckLoop()
+	go gcLoop()
+	go rolloutLoop()
+	go autoscaleLoop()
 
 	// Echo instance
 	e := echo.New()
@@ -122,148 +229,376 @@ func main() {
 	// Middleware
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
+	e.Use(httpMetrics)
+	e.Use(leaderRedirect)
+	e.Use(auditLog)
 
 	// Routes
+	viewer, deployer, admin := requireRole(roleViewer), requireRole(roleDeployer), requireRole(roleAdmin)
 	e.GET("/", hello)
-	e.POST("/service", svc)
-	e.GET("/state", state)
+	e.GET("/metrics", metricsHandler, viewer)
+	e.POST("/service", svc, deployer)
+	e.GET("/state", state, viewer)
+	e.GET("/cluster", clusterStatus, viewer)
+	e.POST("/cluster/join", clusterJoin, admin)
+	e.GET("/nodes/ca", caCert)
+	e.POST("/nodes/tokens", tokenCreate, admin)
+	e.GET("/nodes/tokens", tokensList, admin)
+	e.DELETE("/nodes/tokens/:id", tokenRevoke, admin)
+	e.DELETE("/nodes/:name", nodeDelete, admin)
+	e.POST("/auth/tokens", apiTokenCreate, admin)
+	e.GET("/auth/tokens", apiTokensList, admin)
+	e.DELETE("/auth/tokens/:id", apiTokenRevoke, admin)
+	e.GET("/audit", audit, admin)
+	e.GET("/tasks", tasks, viewer)
+	e.GET("/events", eventsGet, viewer)
+	e.GET("/namespaces", namespacesList, viewer)
+	e.POST("/namespaces", namespaceCreate, admin)
+	e.DELETE("/namespaces/:ns", namespaceDelete, admin)
+	e.GET("/namespaces/:ns/services", nsServicesList, viewer)
+	e.POST("/namespaces/:ns/services", nsServiceCreate, deployer)
+	e.PUT("/namespaces/:ns/services/:name", nsServiceScale, deployer)
+	e.GET("/namespaces/:ns/quota", quotaGet, viewer)
+	e.POST("/secrets", secretCreate, deployer)
+	e.GET("/secrets", secretsList, viewer)
+	e.DELETE("/secrets/:name", secretDelete, deployer)
+	e.POST("/namespaces/:ns/secrets", nsSecretCreate, deployer)
+	e.GET("/namespaces/:ns/secrets", nsSecretsList, viewer)
+	e.DELETE("/namespaces/:ns/secrets/:name", nsSecretDelete, deployer)
+	e.POST("/configs", configCreate, deployer)
+	e.GET("/configs", configsList, viewer)
+	e.GET("/configs/:name", configGet, viewer)
+	e.DELETE("/configs/:name", configDelete, deployer)
+	e.POST("/namespaces/:ns/configs", nsConfigCreate, deployer)
+	e.GET("/namespaces/:ns/configs", nsConfigsList, viewer)
+	e.GET("/namespaces/:ns/configs/:name", nsConfigGet, viewer)
+	e.DELETE("/namespaces/:ns/configs/:name", nsConfigDelete, deployer)
+	e.POST("/registries", registryCreate, deployer)
+	e.GET("/registries", registriesList, viewer)
+	e.DELETE("/registries/:name", registryDelete, deployer)
+	e.POST("/namespaces/:ns/registries", nsRegistryCreate, deployer)
+	e.GET("/namespaces/:ns/registries", nsRegistriesList, viewer)
+	e.DELETE("/namespaces/:ns/registries/:name", nsRegistryDelete, deployer)
+	e.PUT("/namespaces/:ns/quota", quotaSet, admin)
 
 	// Start server
-	e.Logger.Fatal(e.Start(":8080"))
+	e.Logger.Fatal(startHTTP(e))
 
 }
 
 func (s *server) CheckWorker(ctx context.Context, request *pb.Request) (*pb.Response, error) {
+	if !isLeader() {
+		c, err := leaderClient()
+		if err != nil {
+			return nil, err
+		}
+		return c.CheckWorker(forwarded(ctx), request)
+	}
 	node, service, state := request.GetNode(), request.GetService(), request.GetState()
-	command, params, status := checkByNode(node, service, state)
-	return &pb.Response{Command: command, Params: params, Status: status}, nil
+	if service != "nodereg" {
+		recordReport(node, service, request.GetManaged())
+	}
+	if service != "nodereg" && !request.GetManaged() {
+		return &pb.Response{Command: "NoCommand", Params: "Unmanaged container", Status: true}, nil
+	}
+	if event := request.GetEvent(); event != "" {
+		log.Printf("%v on %v: %v, state %v, exit code %v", service, node, event, state, request.GetExitCode())
+	}
+	if pendingDelete(service) {
+		return &pb.Response{Command: "delete", Params: service, Status: false}, nil
+	}
+	return checkByNode(node, service, state), nil
 }
 
 func (s *server) CheckForTask(ctx context.Context, request *pb.TaskRequest) (*pb.TaskResponse, error) {
+	if !isLeader() {
+		c, err := leaderClient()
+		if err != nil {
+			return nil, err
+		}
+		return c.CheckForTask(forwarded(ctx), request)
+	}
 	node := request.GetNode()
-	job, params := checkForTask(node)
-	return &pb.TaskResponse{Job: job, Params: params}, nil
+	return checkForTask(node), nil
+}
+
+func (s *server) Heartbeat(ctx context.Context, request *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	if !isLeader() {
+		c, err := leaderClient()
+		if err != nil {
+			return nil, err
+		}
+		return c.Heartbeat(forwarded(ctx), request)
+	}
+	status := heartbeat(request.GetNode(), request.GetIp())
+	return &pb.HeartbeatResponse{Ttl: int64(nodeTTL.Seconds()), Status: status}, nil
+}
+
+func (s *server) ReportNodeStatus(ctx context.Context, report *pb.NodeStatusReport) (*pb.NodeStatusResponse, error) {
+	if !isLeader() {
+		c, err := leaderClient()
+		if err != nil {
+			return nil, err
+		}
+		return c.ReportNodeStatus(forwarded(ctx), report)
+	}
+	if !heartbeat(report.GetNode(), report.GetIp()) {
+		return &pb.NodeStatusResponse{Registered: false}, nil
+	}
+	commands := reportNodeStatus(report)
+	return &pb.NodeStatusResponse{Commands: commands, Registered: true}, nil
+}
+
+func (s *server) Join(ctx context.Context, request *pb.JoinRequest) (*pb.CertificateResponse, error) {
+	// token binding and certificate serial are written by leader
+	if !isLeader() {
+		c, err := leaderClient()
+		if err != nil {
+			return nil, err
+		}
+		return c.Join(ctx, request)
+	}
+	node := request.GetNode()
+	if !validNodeName.MatchString(node) {
+		return nil, status.Error(codes.InvalidArgument, "wrong node name")
+	}
+	token, err := admitNode(request.GetToken(), node)
+	if err != nil {
+		log.Printf("Rejected join of %v: %v", node, err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	cert, err := issueCert(request.GetCsr(), node, token)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "wrong csr: %v", err)
+	}
+	log.Printf("Issued certificate for node %v", node)
+	return &pb.CertificateResponse{Certificate: cert, Ca: ca.pem}, nil
 }
 
-func checkByNode(node string, service string, state string) (command string, params string, status bool) {
+func (s *server) RenewCertificate(ctx context.Context, request *pb.CertificateRequest) (*pb.CertificateResponse, error) {
+	if !isLeader() {
+		c, err := leaderClient()
+		if err != nil {
+			return nil, err
+		}
+		return c.RenewCertificate(forwarded(ctx), request)
+	}
+	cert, err := issueCert(request.GetCsr(), request.GetNode(), "")
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "wrong csr: %v", err)
+	}
+	log.Printf("Renewed certificate for node %v", request.GetNode())
+	return &pb.CertificateResponse{Certificate: cert, Ca: ca.pem}, nil
+}
+
+func (s *server) ReportTaskStatus(ctx context.Context, request *pb.TaskStatus) (*pb.TaskStatusResponse, error) {
+	if !isLeader() {
+		c, err := leaderClient()
+		if err != nil {
+			return nil, err
+		}
+		return c.ReportTaskStatus(forwarded(ctx), request)
+	}
+	return &pb.TaskStatusResponse{Known: recordTaskStatus(request)}, nil
+}
+
+func checkByNode(node string, service string, state string) (r *pb.Response) {
 	log.Printf("Received message from %v", node)
-	command = "NoCommand"
-	params = fmt.Sprintf("ACK for %v", node)
-	status = true
-	if state != "running" {
-		oldContName := service
-		contName := nameWithSuffix(oldContName[:len(oldContName)-21])
-		kv.DeleteKV(db, oldContName)
-		kv.EjectKV(db, node, oldContName)
-		kv.AppendKV(db, node, contName)
-		command = "recreate"
-		params = fmt.Sprintf("%v %v nginx:alpine", oldContName, contName)
-		status = false
+	restart := pendingRestart(service)
+	contName := ""
+	err := kv.Update(db, func(b *kv.Batch) error {
+		r, contName = checkContainer(b, node, service, state, restart)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to recreate %v: %v", service, err)
+		if restart {
+			markRestart(service)
+		}
+		r = &pb.Response{Command: "NoCommand", Params: fmt.Sprintf("ACK for %v", node), Status: true}
+	} else if contName != "" {
+		containerRecreated(node, service, state, contName)
 	}
 
 	if service == "nodereg" {
+		if !kv.InList(db, "Nodes", node) {
+			recordEvent("node-registered", "", node, fmt.Sprintf("node %v registered", node))
+		}
 		kv.AppendKV(db, "Nodes", node)
+		renewNodeLease(node)
 		fmt.Println(kv.GetKV(db, "Nodes"))
-		params = "Node Registered"
+		r.Params = "Node Registered"
 	}
 	return
 }
 
-func checkForTask(node string) (job string, params string) {
-	job, params = getTaskFromQueue(node)
-	if job != "nojob" {
-		fmt.Println(kv.GetKV(db, node))
+// checkContainer - replace known container which is not running or marked for restart by new one in batch,
+// unknown ones are left to gc
+func checkContainer(b *kv.Batch, node, service, state string, restart bool) (r *pb.Response, contName string) {
+	r = &pb.Response{Command: "NoCommand", Params: fmt.Sprintf("ACK for %v", node), Status: true}
+	if (state == "running" && !restart) || !b.Has(service) {
+		return
+	}
+	oldContName := service
+	svcName := kv.ServiceName(oldContName)
+	contName = nameWithSuffix(svcName)
+	rec := kv.Container{Image: "nginx:alpine", Replicas: 1}
+	b.GetJSON(oldContName, &rec)
+	old := rec
+	b.PutJSON(replacementKey(contName), replacement{Old: oldContName, Node: node, Record: &old})
+	rec.Image = pinnedImage(svcName, rec.Image)
+	r.Configs, rec.Configs = resolveConfigs(svcName, serviceSpec(svcName).Configs)
+	b.Delete(oldContName)
+	b.Eject(kv.NodeKey(node), oldContName)
+	b.Eject(kv.ServiceKey(svcName), oldContName)
+	b.Append(kv.NodeKey(node), contName)
+	b.Append(kv.ServiceKey(svcName), contName)
+	b.PutJSON(contName, rec)
+	r.Command = "recreate"
+	r.Params = kv.Task{ID: nameWithSuffix("Task"), Job: r.Command, Old: oldContName, Container: contName, Service: svcName, Image: rec.Image, CPUs: rec.CPUs, Memory: rec.Memory}.Params()
+	r.Secrets = resolveSecrets(svcName, rec.Secrets)
+	r.Registry = resolveRegistry(svcName, rec.Registry)
+	r.PullPolicy = rec.Pull
+	r.Status = false
+	return
+}
+
+// containerRecreated - record death of container once its replacement is committed
+func containerRecreated(node, oldContName, state, contName string) {
+	if state != "running" {
+		recordEvent("container-died", kv.ServiceName(oldContName), node, fmt.Sprintf("%v is %v, recreating as %v", oldContName, state, contName))
+	}
+}
+
+func checkForTask(node string) (r *pb.TaskResponse) {
+	r = getTaskFromQueue(node)
+	if r.Job != "nojob" {
+		fmt.Println(kv.GetKV(db, kv.NodeKey(node)))
 	}
 	return
 }
 
 func grpcServerStart() {
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", *grpcAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverTLS())), grpc.ChainUnaryInterceptor(grpcMetrics, nodeAuth))
 	pb.RegisterDockeratorServer(s, &server{})
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
 
-func nodesCheckLoop() {
-	for {
-		runningNodes := docker.NodesHealthChecks()
-		nodes, _ := kv.GetKV(db, "Nodes")
-		nodesDesired := strings.Split(nodes, " ")
-		nodesChecked := []string{}
-		for _, nd := range nodesDesired {
-			for _, nr := range runningNodes {
-				if nr == nd {
-					nodesChecked = append(nodesChecked, nr)
-				}
-			}
-		}
-		if len(nodesChecked) != len(nodesDesired) {
-			log.Println("Some node failed! Rebalancing...")
-		}
-		time.Sleep(3 * time.Second)
-	}
-}
-
-func launchService(name, image string, rs int) (tasks []string) {
-	kv.AppendKV(db, "Services", name)
+func addTasks(name string, spec svcConfig, rs int) (tasks []string) {
 	for i := 0; i < rs; i++ {
-		taskName := nameWithSuffix("Task")
-		taskParam := fmt.Sprintf("%v %v %v %v", "create", nameWithSuffix(name), image, 1)
-		kv.PutKV(db, taskName, taskParam)
-		tasks = append(tasks, taskName)
+		task := createTask(name, spec)
+		kv.PutJSON(db, task.ID, task)
+		tasks = append(tasks, task.ID)
 	}
 	return tasks
 }
 
+// createTask - task creating new replica of service
+func createTask(name string, spec svcConfig) kv.Task {
+	return kv.Task{ID: nameWithSuffix("Task"), Job: "create", Container: nameWithSuffix(name), Service: name, Image: spec.Image, Replicas: 1, CPUs: spec.CPUs, Memory: spec.Memory, Secrets: spec.Secrets, Configs: spec.Configs, Registry: spec.Registry, Pull: spec.Pull}
+}
+
 func rebalanceService(node, oldSvcName, image string, rs int) {
 	kv.DeleteKV(db, oldSvcName)
-	kv.EjectKV(db, node, oldSvcName)
+	kv.EjectKV(db, kv.NodeKey(node), oldSvcName)
 	svcName := oldSvcName[:len(oldSvcName)-21]
 	for i := 0; i < rs; i++ {
 		taskName := nameWithSuffix("Task")
-		taskParam := fmt.Sprintf("%v %v %v %v", "recreate", nameWithSuffix(svcName), image, 1)
-		kv.PutKV(db, taskName, taskParam)
+		task := kv.Task{ID: taskName, Job: "recreate", Old: oldSvcName, Container: nameWithSuffix(svcName), Service: svcName, Image: image, Replicas: 1}
+		kv.PutJSON(db, taskName, task)
 	}
 }
 
 func taskToQueueLoop() {
 	for {
+		if !isLeader() {
+			resetTaskQueue()
+			time.Sleep(5 * time.Second)
+			continue
+		}
 		tasks := kv.TasksList(db)
-		if len(tasks) != 0 {
-			for _, v := range tasks {
-				task, _ := kv.GetKV(db, v)
-				taskQueue <- task
-				kv.DeleteKV(db, v)
+		for _, v := range tasks {
+			queuedTasks.Lock()
+			queued := queuedTasks.keys[v]
+			queuedTasks.keys[v] = true
+			queuedTasks.Unlock()
+			if !queued {
+				taskQueue <- v
 			}
 		}
 		time.Sleep(5 * time.Second)
 	}
 }
 
-func getTaskFromQueue(node string) (job string, params string) {
-	if len(taskQueue) > 0 {
-		task := <-taskQueue
-		t := strings.Split(task, " ")
-		job = t[0]
-		params = strings.Join(t[1:], " ")
-		contName := t[1]
-		contParam := strings.Join(t[2:], " ")
-		svcName := contName[:len(contName)-21]
-		kv.AppendKV(db, node, contName)
-		kv.AppendKV(db, "Services", svcName)
-		kv.AppendKV(db, svcName, contName)
-		kv.AppendKV(db, contName, contParam)
+// resetTaskQueue - drop queued tasks after losing leadership, new leader requeues them from db
+func resetTaskQueue() {
+	queuedTasks.Lock()
+	defer queuedTasks.Unlock()
+	for len(taskQueue) > 0 {
+		<-taskQueue
+	}
+	queuedTasks.keys = map[string]bool{}
+}
+
+func getTaskFromQueue(node string) (r *pb.TaskResponse) {
+	for len(taskQueue) > 0 {
+		taskName := <-taskQueue
+		task := kv.Task{}
+		err := kv.GetJSON(db, taskName, &task)
+		kv.DeleteKV(db, taskName)
+		queuedTasks.Lock()
+		delete(queuedTasks.keys, taskName)
+		queuedTasks.Unlock()
+		if err != nil {
+			log.Printf("Failed to decode task %v: %v", taskName, err)
+			continue
+		}
+		task.Image = pinnedImage(task.Service, task.Image)
+		r = &pb.TaskResponse{Job: task.Job, Params: task.Params(), PullPolicy: task.Pull}
+		r.Secrets = resolveSecrets(task.Service, task.Secrets)
+		r.Configs, task.Configs = resolveConfigs(task.Service, task.Configs)
+		r.Registry = resolveRegistry(task.Service, task.Registry)
+		if task.Job == "recreate" {
+			kv.PutJSON(db, replacementKey(task.Container), replacement{Old: task.Old, Node: node, Task: &task})
+		}
+		recordDispatch(task.Container)
+		auditDispatch(node, task)
+		taskResults.WithLabelValues("dispatched").Inc()
+		recordEvent("task-dispatched", task.Service, node, fmt.Sprintf("%v of %v dispatched", task.Job, task.Container))
+		kv.AppendKV(db, kv.NodeKey(node), task.Container)
+		kv.AppendKV(db, "Services", task.Service)
+		kv.AppendKV(db, kv.ServiceKey(task.Service), task.Container)
+		kv.PutJSON(db, task.Container, kv.Container{Image: task.Image, Replicas: task.Replicas, CPUs: task.CPUs, Memory: task.Memory, Secrets: task.Secrets, Configs: task.Configs, Registry: task.Registry, Pull: task.Pull})
 		return
 	}
 	log.Println("No task")
-	job = "nojob"
-	params = "noparams"
-	return
+	return &pb.TaskResponse{Job: "nojob", Params: "noparams"}
+}
+
+// migrateState - upgrade stored schema on leader, followers wait for replicated upgrade
+func migrateState() {
+	for {
+		if isLeader() {
+			changes, err := kv.Migrate(db, false)
+			if err != nil {
+				log.Fatalf("migration failed: %v", err)
+			}
+			if len(changes) > 0 {
+				log.Printf("Schema migrated to v%v, %v records changed", kv.LatestVersion(), len(changes))
+			}
+			return
+		}
+		if kv.SchemaVersion(db) >= kv.LatestVersion() {
+			return
+		}
+		time.Sleep(time.Second)
+	}
 }
 
 func nameWithSuffix(name string) (finalName string) {
@@ -0,0 +1,15 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"testing"
+)
+
+// testStore - package db backed by temporary local store
+func testStore(t *testing.T) {
+	db = kv.InitDB(t.TempDir())
+	t.Cleanup(func() {
+		db.Close()
+		db = nil
+	})
+}
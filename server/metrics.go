@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	kv "dockerator/kvstore"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerator_http_requests_total",
+		Help: "REST API requests by method, route and status code.",
+	}, []string{"method", "path", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dockerator_http_request_duration_seconds",
+		Help:    "REST API request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+	grpcCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerator_grpc_calls_total",
+		Help: "Agent gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dockerator_grpc_call_duration_seconds",
+		Help:    "Agent gRPC call latency by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	taskResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerator_tasks_total",
+		Help: "Tasks dispatched to agents and their reported results.",
+	}, []string{"result"})
+)
+
+var (
+	queueDepthDesc      = prometheus.NewDesc("dockerator_task_queue_depth", "Tasks queued for dispatch on this member.", nil, nil)
+	pendingTasksDesc    = prometheus.NewDesc("dockerator_tasks_pending", "Tasks stored and waiting for agent.", nil, nil)
+	nodesDesc           = prometheus.NewDesc("dockerator_nodes", "Registered nodes by status.", []string{"status"}, nil)
+	desiredReplicasDesc = prometheus.NewDesc("dockerator_service_replicas_desired", "Replicas requested by service spec.", []string{"namespace", "service"}, nil)
+	runningReplicasDesc = prometheus.NewDesc("dockerator_service_replicas_running", "Replicas reported running by agents.", []string{"namespace", "service"}, nil)
+	nodeCPUDesc         = prometheus.NewDesc("dockerator_node_containers_cpu_percent", "CPU used by managed containers of node, percent of one core.", []string{"node"}, nil)
+	nodeMemoryDesc      = prometheus.NewDesc("dockerator_node_containers_memory_bytes", "Memory used by managed containers of node.", []string{"node"}, nil)
+)
+
+// clusterCollector - gauges read from store on scrape
+type clusterCollector struct{}
+
+func (clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{queueDepthDesc, pendingTasksDesc, nodesDesc, desiredReplicasDesc, runningReplicasDesc, nodeCPUDesc, nodeMemoryDesc} {
+		ch <- d
+	}
+}
+
+func (clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(len(taskQueue)))
+	ch <- prometheus.MustNewConstMetric(pendingTasksDesc, prometheus.GaugeValue, float64(len(kv.TasksList(db))))
+	nodes := map[string]int{"up": 0, "down": 0}
+	for _, n := range kv.GetList(db, "Nodes") {
+		nodes[nodeStatus(n)]++
+		// totals of last report, agents serve per container stats themselves
+		if stats := nodeInventory(n).GetStats(); stats != nil {
+			ch <- prometheus.MustNewConstMetric(nodeCPUDesc, prometheus.GaugeValue, stats.GetCpuPercent(), n)
+			ch <- prometheus.MustNewConstMetric(nodeMemoryDesc, prometheus.GaugeValue, float64(stats.GetMemoryUsage()), n)
+		}
+	}
+	for s, count := range nodes {
+		ch <- prometheus.MustNewConstMetric(nodesDesc, prometheus.GaugeValue, float64(count), s)
+	}
+	running := map[string]int{}
+	for name, c := range reportedContainers() {
+		if c.GetState() == "running" {
+			running[kv.ServiceName(name)]++
+		}
+	}
+	for _, s := range kv.GetList(db, "Services") {
+		ns, name := kv.SplitName(s)
+		ch <- prometheus.MustNewConstMetric(desiredReplicasDesc, prometheus.GaugeValue, float64(serviceSpec(s).Replicas), ns, name)
+		ch <- prometheus.MustNewConstMetric(runningReplicasDesc, prometheus.GaugeValue, float64(running[s]), ns, name)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration, grpcCalls, grpcDuration, taskResults, clusterCollector{})
+}
+
+// httpMetrics - count and time REST calls by route, not raw path
+func httpMetrics(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+		code := c.Response().Status
+		if he, ok := err.(*echo.HTTPError); ok {
+			code = he.Code
+		}
+		method, path := c.Request().Method, c.Path()
+		httpRequests.WithLabelValues(method, path, strconv.Itoa(code)).Inc()
+		httpDuration.WithLabelValues(method, path).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// grpcMetrics - count and time agent calls, chained before node auth so rejected calls are counted too
+func grpcMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	grpcCalls.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	grpcDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+var metricsHandler = echo.WrapHandler(promhttp.Handler())
@@ -0,0 +1,141 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/labstack/echo/v4"
+)
+
+// validName - namespaces and services are DNS labels, "." separates them in store names
+var validName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type namespaceConfig struct {
+	Name string `json:"name"`
+}
+
+// namespaces - default namespace always exists
+func namespaces() []string {
+	return append([]string{kv.DefaultNamespace}, kv.GetList(db, "Namespaces")...)
+}
+
+func namespaceExists(ns string) bool {
+	return ns == kv.DefaultNamespace || kv.InList(db, "Namespaces", ns)
+}
+
+// namespaceServices - qualified names of services in namespace
+func namespaceServices(ns string) (services []string) {
+	for _, s := range kv.GetList(db, "Services") {
+		if n, _ := kv.SplitName(s); n == ns {
+			services = append(services, s)
+		}
+	}
+	return
+}
+
+func namespacesList(c echo.Context) error {
+	return c.JSON(http.StatusOK, namespaces())
+}
+
+func namespaceCreate(c echo.Context) error {
+	ns := namespaceConfig{}
+	if err := c.Bind(&ns); err != nil {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	c.Set(auditTarget, ns.Name)
+	if !validName.MatchString(ns.Name) {
+		return c.String(http.StatusBadRequest, "Wrong namespace name")
+	}
+	if namespaceExists(ns.Name) {
+		return c.String(http.StatusConflict, "Namespace exists")
+	}
+	kv.AppendKV(db, "Namespaces", ns.Name)
+	log.Printf("Namespace %v created", ns.Name)
+	return c.JSON(http.StatusOK, ns)
+}
+
+func namespaceDelete(c echo.Context) error {
+	ns := c.Param("ns")
+	if ns == kv.DefaultNamespace {
+		return c.String(http.StatusBadRequest, "Default namespace can't be deleted")
+	}
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	if len(namespaceServices(ns)) > 0 {
+		return c.String(http.StatusConflict, "Namespace has services")
+	}
+	if len(kv.KeysList(db, configKey(ns+"."))) > 0 || len(kv.KeysList(db, secretKey(ns+"."))) > 0 || len(kv.KeysList(db, registryKey(ns+"."))) > 0 {
+		return c.String(http.StatusConflict, "Namespace has configs, secrets or registries")
+	}
+	kv.EjectKV(db, "Namespaces", ns)
+	kv.DeleteKV(db, quotaKey(ns))
+	log.Printf("Namespace %v deleted", ns)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func nsServicesList(c echo.Context) error {
+	ns := c.Param("ns")
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	return c.JSON(http.StatusOK, servicesState(namespaceServices(ns), reportedContainers()))
+}
+
+func nsServiceCreate(c echo.Context) error {
+	return createService(c, c.Param("ns"))
+}
+
+// createService - schedule service replicas in namespace
+func createService(c echo.Context, ns string) error {
+	service := svcConfig{}
+	if err := c.Bind(&service); err != nil {
+		log.Printf("Failed to decode json: %v", err)
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	name := kv.QualifiedName(ns, service.Name)
+	c.Set(auditTarget, name)
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	if !validName.MatchString(service.Name) || service.Image == "" || service.Replicas < 0 || service.CPUs < 0 || service.Memory < 0 {
+		return c.String(http.StatusBadRequest, "Wrong service config")
+	}
+	if !pullPolicies[service.Pull] {
+		return c.String(http.StatusBadRequest, "Wrong pull policy, use always, if-not-present or never")
+	}
+	// digest is pinned by first pull of deploy
+	service.Digest = ""
+	if err := checkAutoscale(service); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if service.Autoscale != nil {
+		service.Replicas = clampReplicas(service.Autoscale, service.Replicas)
+	}
+	if err := checkSecretRefs(ns, service.Secrets); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := checkConfigRefs(ns, service.Configs); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if service.Registry != "" && !kv.KeyExist(db, registryKey(kv.QualifiedName(ns, service.Registry))) {
+		return c.String(http.StatusBadRequest, "Unknown registry "+service.Registry)
+	}
+	if kv.InList(db, "Services", name) {
+		return c.String(http.StatusConflict, "Service exists, scale it with PUT /namespaces/<ns>/services/<name>")
+	}
+	err := createSpec(name, service)
+	if err == errServiceExists {
+		return c.String(http.StatusConflict, "Service exists, scale it with PUT /namespaces/<ns>/services/<name>")
+	}
+	if _, ok := err.(quotaError); ok {
+		return c.String(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	addTasks(name, service, service.Replicas)
+	return c.JSON(http.StatusOK, service)
+}
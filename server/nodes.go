@@ -0,0 +1,122 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// validNodeName - lowercase DNS name or IP address agents use by default, can't clash with system keys
+var validNodeName = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]{0,251}[a-z0-9])?$`)
+
+func leaseKey(node string) string {
+	return "Lease-" + node
+}
+
+func nodeStateKey(node string) string {
+	return "NodeState-" + node
+}
+
+func nodeIPKey(node string) string {
+	return "NodeIP-" + node
+}
+
+func inventoryKey(node string) string {
+	return "Inventory-" + node
+}
+
+// nodeIP - IP advertised by node agent, node name is its IP by default
+func nodeIP(node string) string {
+	ip, err := kv.GetKV(db, nodeIPKey(node))
+	if err != nil || ip == "" {
+		return node
+	}
+	return ip
+}
+
+func renewNodeLease(node string) {
+	if err := kv.GrantLease(db, leaseKey(node), *nodeTTL); err != nil {
+		log.Printf("Failed to renew lease of %v: %v", node, err)
+	}
+}
+
+// heartbeat - renew lease of registered node, false asks agent to register again
+func heartbeat(node, ip string) bool {
+	if !kv.InList(db, "Nodes", node) {
+		log.Printf("Heartbeat from unknown node %v", node)
+		return false
+	}
+	renewNodeLease(node)
+	if ip != "" && ip != nodeIP(node) {
+		kv.PutKV(db, nodeIPKey(node), ip)
+	}
+	return true
+}
+
+// nodeStatus - up or down as last seen by nodes check loop
+func nodeStatus(node string) string {
+	status, err := kv.GetKV(db, nodeStateKey(node))
+	if err != nil {
+		return "unknown"
+	}
+	return status
+}
+
+// nodesCheckLoop - mark nodes with expired lease as down
+func nodesCheckLoop() {
+	for {
+		if !isLeader() {
+			time.Sleep(3 * time.Second)
+			continue
+		}
+		for _, n := range kv.GetList(db, "Nodes") {
+			status := "down"
+			if kv.LeaseAlive(db, leaseKey(n)) {
+				status = "up"
+			}
+			if status == nodeStatus(n) {
+				continue
+			}
+			kv.PutKV(db, nodeStateKey(n), status)
+			if status == "down" {
+				log.Printf("%v node is DOWN", n)
+			} else {
+				log.Printf("%v node is UP", n)
+			}
+			recordEvent("node-"+status, "", n, fmt.Sprintf("node %v is %v", n, status))
+		}
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// nodeDelete - forget node and its containers, revoke its certificates and token it joined with
+func nodeDelete(c echo.Context) error {
+	name := c.Param("name")
+	c.Set(auditTarget, name)
+	if !kv.InList(db, "Nodes", name) {
+		return c.String(http.StatusNotFound, "Unknown node")
+	}
+	rec := nodeCert{}
+	kv.GetJSON(db, nodeCertKey(name), &rec)
+	if err := kv.PutJSON(db, nodeCertKey(name), nodeCert{Revoked: true}); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if rec.Token != "" && rec.Token != staticTokenID {
+		kv.DeleteKV(db, tokenKey(rec.Token))
+	}
+	for _, cont := range kv.GetList(db, kv.NodeKey(name)) {
+		removeContainer(name, cont)
+	}
+	kv.EjectKV(db, "Nodes", name)
+	for _, k := range []string{kv.NodeKey(name), leaseKey(name), nodeStateKey(name), nodeIPKey(name), inventoryKey(name)} {
+		kv.DeleteKV(db, k)
+	}
+	log.Printf("Node %v removed, its certificates are revoked", name)
+	recordEvent("node-removed", "", name, fmt.Sprintf("node %v removed", name))
+	return c.NoContent(http.StatusNoContent)
+}
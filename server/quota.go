@@ -0,0 +1,245 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// quota - namespace limits, zero means unlimited
+type quota struct {
+	Services int     `json:"services"`
+	Replicas int     `json:"rs"`
+	CPUs     float64 `json:"cpus"`
+	Memory   int64   `json:"memory"`
+}
+
+type quotaResponse struct {
+	Quota quota `json:"quota"`
+	Usage quota `json:"usage"`
+}
+
+type scaleRequest struct {
+	Replicas int `json:"rs"`
+}
+
+func quotaKey(ns string) string {
+	return "Quota-" + ns
+}
+
+func specKey(service string) string {
+	return "Spec-" + service
+}
+
+// quotaError - change rejected by namespace quota
+type quotaError string
+
+func (e quotaError) Error() string {
+	return string(e)
+}
+
+var errServiceExists = errors.New("service exists")
+
+// serviceSpec - stored spec, services created before specs were kept get replicas from their containers
+func serviceSpec(service string) (spec svcConfig) {
+	kv.Update(db, func(b *kv.Batch) error {
+		spec = batchSpec(b, service)
+		return nil
+	})
+	return
+}
+
+func batchSpec(b *kv.Batch, service string) (spec svcConfig) {
+	if err := b.GetJSON(specKey(service), &spec); err != nil {
+		spec = svcConfig{}
+		_, spec.Name = kv.SplitName(service)
+		spec.Replicas = len(b.GetList(kv.ServiceKey(service)))
+	}
+	return
+}
+
+func namespaceQuota(ns string) (q quota) {
+	kv.Update(db, func(b *kv.Batch) error {
+		q = batchQuota(b, ns)
+		return nil
+	})
+	return
+}
+
+func batchQuota(b *kv.Batch, ns string) (q quota) {
+	if b.Has(quotaKey(ns)) {
+		if err := b.GetJSON(quotaKey(ns), &q); err != nil {
+			log.Printf("Failed to decode quota of %v: %v", ns, err)
+		}
+	}
+	return
+}
+
+// namespaceUsage - resources requested by specs of namespace services
+func namespaceUsage(ns string) (u quota) {
+	kv.Update(db, func(b *kv.Batch) error {
+		u = batchUsage(b, ns)
+		return nil
+	})
+	return
+}
+
+func batchUsage(b *kv.Batch, ns string) (u quota) {
+	for _, s := range b.GetList("Services") {
+		if n, _ := kv.SplitName(s); n != ns {
+			continue
+		}
+		spec := batchSpec(b, s)
+		u.Services++
+		u.Replicas += spec.Replicas
+		u.CPUs += spec.CPUs * float64(spec.Replicas)
+		u.Memory += spec.Memory * int64(spec.Replicas)
+	}
+	return
+}
+
+// checkQuota - error if namespace usage with change exceeds quota, quota and specs read by batch
+// are compared on its commit so concurrent changes can't exceed quota together
+func checkQuota(b *kv.Batch, ns string, change quota) error {
+	q, u := batchQuota(b, ns), batchUsage(b, ns)
+	switch {
+	case q.Services > 0 && u.Services+change.Services > q.Services:
+		return quotaError(fmt.Sprintf("services quota exceeded: %v of %v", u.Services+change.Services, q.Services))
+	case q.Replicas > 0 && u.Replicas+change.Replicas > q.Replicas:
+		return quotaError(fmt.Sprintf("replicas quota exceeded: %v of %v", u.Replicas+change.Replicas, q.Replicas))
+	case q.CPUs > 0 && u.CPUs+change.CPUs > q.CPUs:
+		return quotaError(fmt.Sprintf("cpus quota exceeded: %v of %v", u.CPUs+change.CPUs, q.CPUs))
+	case q.Memory > 0 && u.Memory+change.Memory > q.Memory:
+		return quotaError(fmt.Sprintf("memory quota exceeded: %v of %v MB", u.Memory+change.Memory, q.Memory))
+	}
+	return nil
+}
+
+// createSpec - add service with its spec if namespace quota allows it
+func createSpec(name string, spec svcConfig) error {
+	ns, _ := kv.SplitName(name)
+	usage := specUsage(spec, spec.Replicas)
+	usage.Services = 1
+	return kv.Update(db, func(b *kv.Batch) error {
+		if b.InList("Services", name) {
+			return errServiceExists
+		}
+		if err := checkQuota(b, ns, usage); err != nil {
+			return err
+		}
+		b.Append("Services", name)
+		return b.PutJSON(specKey(name), spec)
+	})
+}
+
+// scaleSpec - store rs replicas in spec of service, growth is checked against namespace quota
+func scaleSpec(name string, rs int) (old svcConfig, err error) {
+	ns, _ := kv.SplitName(name)
+	err = kv.Update(db, func(b *kv.Batch) error {
+		old = batchSpec(b, name)
+		if rs > old.Replicas {
+			if err := checkQuota(b, ns, specUsage(old, rs-old.Replicas)); err != nil {
+				return err
+			}
+		}
+		spec := old
+		spec.Replicas = rs
+		return b.PutJSON(specKey(name), spec)
+	})
+	return
+}
+
+// specUsage - resources of rs replicas of spec
+func specUsage(spec svcConfig, rs int) quota {
+	return quota{Replicas: rs, CPUs: spec.CPUs * float64(rs), Memory: spec.Memory * int64(rs)}
+}
+
+func quotaGet(c echo.Context) error {
+	ns := c.Param("ns")
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	return c.JSON(http.StatusOK, quotaResponse{namespaceQuota(ns), namespaceUsage(ns)})
+}
+
+func quotaSet(c echo.Context) error {
+	ns := c.Param("ns")
+	// namespace admins can't raise own quota
+	if tokenNs, _ := c.Get("namespace").(string); tokenNs != "" {
+		return c.String(http.StatusForbidden, "Forbidden")
+	}
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	q := quota{}
+	if err := c.Bind(&q); err != nil || q.Services < 0 || q.Replicas < 0 || q.CPUs < 0 || q.Memory < 0 {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	if err := kv.PutJSON(db, quotaKey(ns), q); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	log.Printf("Quota of %v set to %+v", ns, q)
+	return c.JSON(http.StatusOK, quotaResponse{q, namespaceUsage(ns)})
+}
+
+func nsServiceScale(c echo.Context) error {
+	ns := c.Param("ns")
+	name := kv.QualifiedName(ns, c.Param("name"))
+	c.Set(auditTarget, name)
+	req := scaleRequest{}
+	if err := c.Bind(&req); err != nil || req.Replicas < 0 {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	if !kv.InList(db, "Services", name) {
+		return c.String(http.StatusNotFound, "Unknown service")
+	}
+	spec, err := scaleSpec(name, req.Replicas)
+	if _, ok := err.(quotaError); ok {
+		return c.String(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	scaleService(name, spec, req.Replicas)
+	user, _ := c.Get("user").(string)
+	recordEvent("service-scaled", name, "", fmt.Sprintf("scaled from %v to %v replicas by %v", spec.Replicas, req.Replicas, user))
+	spec.Replicas = req.Replicas
+	return c.JSON(http.StatusOK, spec)
+}
+
+// scaleService - add tasks or remove pending tasks and containers to reach rs replicas stored by scaleSpec
+func scaleService(name string, spec svcConfig, rs int) {
+	containers := kv.GetList(db, kv.ServiceKey(name))
+	pending := []string{}
+	for _, t := range kv.TasksList(db) {
+		task := kv.Task{}
+		if err := kv.GetJSON(db, t, &task); err == nil && task.Service == name {
+			pending = append(pending, t)
+		}
+	}
+	current := len(containers) + len(pending)
+	if rs > current {
+		addTasks(name, spec, rs-current)
+	}
+	for ; current > rs && len(pending) > 0; current-- {
+		kv.DeleteKV(db, pending[0])
+		pending = pending[1:]
+	}
+	for ; current > rs && len(containers) > 0; current-- {
+		c := containers[len(containers)-1]
+		containers = containers[:len(containers)-1]
+		for _, n := range kv.GetList(db, "Nodes") {
+			if kv.InList(db, kv.NodeKey(n), c) {
+				removeContainer(n, c)
+			}
+		}
+		kv.DeleteKV(db, c)
+		kv.EjectKV(db, kv.ServiceKey(name), c)
+		markDelete(c)
+	}
+	log.Printf("Service %v scaled to %v", name, rs)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"testing"
+)
+
+func quotaCheck(ns string, change quota) (err error) {
+	kv.Update(db, func(b *kv.Batch) error {
+		err = checkQuota(b, ns, change)
+		return nil
+	})
+	return
+}
+
+func TestCheckQuota(t *testing.T) {
+	testStore(t)
+	kv.PutJSON(db, "Services", []string{"web", "team.api", "team.worker"})
+	kv.PutJSON(db, specKey("web"), svcConfig{Name: "web", Replicas: 10, CPUs: 4})
+	kv.PutJSON(db, specKey("team.api"), svcConfig{Name: "api", Replicas: 2, CPUs: 0.5, Memory: 256})
+	// service created before specs were stored counts its containers
+	kv.PutJSON(db, kv.ServiceKey("team.worker"), []string{"team.worker-cb0j2nq7f0s8aqq0ab10"})
+	if err := quotaCheck("team", quota{Services: 5, Replicas: 100}); err != nil {
+		t.Fatalf("namespace without quota: %v", err)
+	}
+	kv.PutJSON(db, quotaKey("team"), quota{Services: 3, Replicas: 4, CPUs: 2, Memory: 1024})
+	if u := namespaceUsage("team"); u != (quota{Services: 2, Replicas: 3, CPUs: 1, Memory: 512}) {
+		t.Fatalf("usage = %+v", u)
+	}
+	for _, c := range []struct {
+		change quota
+		fails  bool
+	}{
+		{quota{Services: 1, Replicas: 1}, false},
+		{quota{Services: 2}, true},
+		{quota{Replicas: 2}, true},
+		{quota{CPUs: 1}, false},
+		{quota{CPUs: 1.5}, true},
+		{quota{Memory: 600}, true},
+	} {
+		err := quotaCheck("team", c.change)
+		if _, ok := err.(quotaError); (err != nil) != c.fails || (err != nil && !ok) {
+			t.Errorf("change %+v: %v", c.change, err)
+		}
+	}
+	// other namespaces are not counted
+	if err := quotaCheck(kv.DefaultNamespace, quota{Replicas: 100}); err != nil {
+		t.Fatalf("default namespace: %v", err)
+	}
+}
+
+func TestCreateAndScaleSpec(t *testing.T) {
+	testStore(t)
+	kv.PutJSON(db, quotaKey("team"), quota{Replicas: 3})
+	if err := createSpec("team.api", svcConfig{Name: "api", Image: "app", Replicas: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := createSpec("team.api", svcConfig{Name: "api", Image: "app", Replicas: 1}); err != errServiceExists {
+		t.Fatalf("second create = %v", err)
+	}
+	if err := createSpec("team.web", svcConfig{Name: "web", Image: "app", Replicas: 2}); err == nil {
+		t.Fatal("create over quota succeeded")
+	}
+	if _, err := scaleSpec("team.api", 4); err == nil {
+		t.Fatal("scale over quota succeeded")
+	}
+	old, err := scaleSpec("team.api", 3)
+	if err != nil || old.Replicas != 2 || serviceSpec("team.api").Replicas != 3 {
+		t.Fatalf("scale = %+v, %v", old, err)
+	}
+	// scale down is allowed even when quota was lowered below usage
+	kv.PutJSON(db, quotaKey("team"), quota{Replicas: 1})
+	if _, err := scaleSpec("team.api", 2); err != nil {
+		t.Fatalf("scale down: %v", err)
+	}
+}
+
+func TestScaleKeepsConcurrentSpecEdits(t *testing.T) {
+	testStore(t)
+	if err := createSpec("web", svcConfig{Name: "web", Image: "nginx", Replicas: 1}); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := scaleSpec("web", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// digest pinned between spec txn and task scheduling stays
+	pinDigest("web", "sha256:aa")
+	scaleService("web", spec, 2)
+	if got := serviceSpec("web"); got.Replicas != 2 || got.Digest != "sha256:aa" {
+		t.Fatalf("spec = %+v", got)
+	}
+	if len(kv.TasksList(db)) != 2 {
+		t.Fatalf("tasks = %v", kv.TasksList(db))
+	}
+}
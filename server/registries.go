@@ -0,0 +1,161 @@
+package main
+
+import (
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// storedRegistry - registry credential, password is encrypted like secrets
+type storedRegistry struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Server    string    `json:"server"`
+	Username  string    `json:"username"`
+	Password  string    `json:"password,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+type registryRequest struct {
+	Name     string `json:"name"`
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func registryKey(qualified string) string {
+	return "Registry-" + qualified
+}
+
+// resolveRegistry - credential selected by service, nil for public images
+func resolveRegistry(service, name string) *pb.Registry {
+	if name == "" {
+		return nil
+	}
+	ns, _ := kv.SplitName(service)
+	qualified := kv.QualifiedName(ns, name)
+	r := storedRegistry{}
+	if err := kv.GetJSON(db, registryKey(qualified), &r); err != nil {
+		log.Printf("Failed to resolve registry %v of %v: %v", name, service, err)
+		return nil
+	}
+	password, err := decrypt(registryKey(qualified), r.Password)
+	if err != nil {
+		log.Printf("Failed to resolve registry %v of %v: %v", name, service, err)
+		return nil
+	}
+	return &pb.Registry{Server: r.Server, Username: r.Username, Password: string(password)}
+}
+
+// registryUsers - services of namespace pulling with credential in spec or in queued tasks
+func registryUsers(ns, name string) (services []string) {
+	for _, s := range namespaceServices(ns) {
+		if serviceSpec(s).Registry == name {
+			services = append(services, s)
+		}
+	}
+	for _, t := range kv.TasksList(db) {
+		task := kv.Task{}
+		if err := kv.GetJSON(db, t, &task); err != nil || task.Registry != name || contains(services, task.Service) {
+			continue
+		}
+		if taskNs, _ := kv.SplitName(task.Service); taskNs == ns {
+			services = append(services, task.Service)
+		}
+	}
+	return
+}
+
+func registryCreate(c echo.Context) error {
+	return createRegistry(c, kv.DefaultNamespace)
+}
+
+func nsRegistryCreate(c echo.Context) error {
+	return createRegistry(c, c.Param("ns"))
+}
+
+// createRegistry - store or replace credential, new one is used by next pulls
+func createRegistry(c echo.Context, ns string) error {
+	req := registryRequest{}
+	err := c.Bind(&req)
+	name := kv.QualifiedName(ns, req.Name)
+	c.Set(auditTarget, name)
+	// password never gets to audit log
+	c.Set(auditPayload, fmt.Sprintf(`{"name":%q,"server":%q,"username":%q}`, req.Name, req.Server, req.Username))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	if secretsAEAD == nil {
+		return c.String(http.StatusServiceUnavailable, "Secrets are disabled, set -secrets-key")
+	}
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	if !validName.MatchString(req.Name) || req.Server == "" || req.Username == "" {
+		return c.String(http.StatusBadRequest, "Wrong registry config")
+	}
+	password, err := encryptSecret(registryKey(name), []byte(req.Password))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	r := storedRegistry{Name: req.Name, Namespace: ns, Server: req.Server, Username: req.Username, Password: password, Created: time.Now()}
+	if err := kv.PutJSON(db, registryKey(name), r); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	log.Printf("Registry credential %v for %v stored", name, req.Server)
+	r.Password = ""
+	return c.JSON(http.StatusOK, r)
+}
+
+func registriesList(c echo.Context) error {
+	return listRegistries(c, kv.DefaultNamespace)
+}
+
+func nsRegistriesList(c echo.Context) error {
+	return listRegistries(c, c.Param("ns"))
+}
+
+// listRegistries - servers and usernames, passwords are never returned
+func listRegistries(c echo.Context, ns string) error {
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	registries := []storedRegistry{}
+	for _, k := range kv.KeysList(db, registryKey("")) {
+		r := storedRegistry{}
+		if err := kv.GetJSON(db, k, &r); err != nil || r.Namespace != ns {
+			continue
+		}
+		r.Password = ""
+		registries = append(registries, r)
+	}
+	return c.JSON(http.StatusOK, registries)
+}
+
+func registryDelete(c echo.Context) error {
+	return deleteRegistry(c, kv.DefaultNamespace)
+}
+
+func nsRegistryDelete(c echo.Context) error {
+	return deleteRegistry(c, c.Param("ns"))
+}
+
+func deleteRegistry(c echo.Context, ns string) error {
+	name := kv.QualifiedName(ns, c.Param("name"))
+	c.Set(auditTarget, name)
+	if !kv.KeyExist(db, registryKey(name)) {
+		return c.String(http.StatusNotFound, "Unknown registry")
+	}
+	if users := registryUsers(ns, c.Param("name")); len(users) > 0 {
+		return c.String(http.StatusConflict, fmt.Sprintf("Registry is used by %v", strings.Join(users, ", ")))
+	}
+	kv.DeleteKV(db, registryKey(name))
+	log.Printf("Registry credential %v deleted", name)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"fmt"
+	"testing"
+)
+
+func TestRegistryUsers(t *testing.T) {
+	testStore(t)
+	kv.PutJSON(db, "Services", []string{"team.api"})
+	kv.PutJSON(db, specKey("team.api"), svcConfig{Name: "api", Registry: "ghcr"})
+	// service deleted while its task waits in queue
+	kv.PutJSON(db, "Task-cb0j2nq7f0s8aqq0ab10", kv.Task{Job: "create", Service: "team.old", Registry: "ghcr"})
+	kv.PutJSON(db, "Task-cb0j2nq7f0s8aqq0ab20", kv.Task{Job: "create", Service: "team.api", Registry: "ghcr"})
+	kv.PutJSON(db, "Task-cb0j2nq7f0s8aqq0ab30", kv.Task{Job: "create", Service: "other.api", Registry: "ghcr"})
+	if got := registryUsers("team", "ghcr"); fmt.Sprint(got) != "[team.api team.old]" {
+		t.Fatalf("users = %v", got)
+	}
+	if got := registryUsers("team", "quay"); len(got) != 0 {
+		t.Fatalf("users of unused credential = %v", got)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"log"
+)
+
+// reportNodeStatus - apply full node inventory, returns commands fixing its containers
+func reportNodeStatus(report *pb.NodeStatusReport) (commands []*pb.Response) {
+	node := report.GetNode()
+	// in-memory restart marks are taken once, batch below may run several times
+	restarts := map[string]bool{}
+	for _, c := range report.GetContainers() {
+		recordReport(node, c.GetName(), c.GetManaged())
+		if c.GetManaged() {
+			restarts[c.GetName()] = pendingRestart(c.GetName())
+		}
+	}
+	recreated := map[string]string{}
+	// inventory and replacements of its containers are one txn, /state never sees half applied report
+	err := kv.Update(db, func(b *kv.Batch) error {
+		commands, recreated = nil, map[string]string{}
+		if err := b.PutJSON(inventoryKey(node), report); err != nil {
+			return err
+		}
+		for _, c := range report.GetContainers() {
+			if !c.GetManaged() {
+				continue
+			}
+			if b.Has(pendingDeleteKey(c.GetName())) {
+				commands = append(commands, &pb.Response{Command: "delete", Params: c.GetName(), Status: false})
+				continue
+			}
+			if r, contName := checkContainer(b, node, c.GetName(), c.GetState(), restarts[c.GetName()]); !r.Status {
+				commands = append(commands, r)
+				recreated[c.GetName()] = contName
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to apply status report of %v: %v", node, err)
+		for c := range restarts {
+			if restarts[c] {
+				markRestart(c)
+			}
+		}
+		return nil
+	}
+	for _, c := range report.GetContainers() {
+		if contName, ok := recreated[c.GetName()]; ok {
+			containerRecreated(node, c.GetName(), c.GetState(), contName)
+		}
+	}
+	return
+}
+
+// nodeInventory - last status report of node
+func nodeInventory(node string) (report *pb.NodeStatusReport) {
+	report = &pb.NodeStatusReport{}
+	if kv.KeyExist(db, inventoryKey(node)) {
+		if err := kv.GetJSON(db, inventoryKey(node), report); err != nil {
+			log.Printf("Failed to decode inventory of %v: %v", node, err)
+		}
+	}
+	return
+}
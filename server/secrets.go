@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// secretsAEAD - cipher of secret values at rest, nil while -secrets-key is not set
+var secretsAEAD cipher.AEAD
+
+// storedSecret - secret value encrypted with key of server, name is bound to ciphertext
+type storedSecret struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Data      string    `json:"data,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+type secretRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func secretKey(qualified string) string {
+	return "Secret-" + qualified
+}
+
+// initSecrets - load base64 AES-256 key, all cluster members need the same one
+func initSecrets() {
+	if *secretsKey == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(*secretsKey)
+	if err != nil {
+		log.Fatalf("failed to read secrets key: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		log.Fatal("secrets key must be 32 bytes in base64")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatalf("failed to init secrets cipher: %v", err)
+	}
+	if secretsAEAD, err = cipher.NewGCM(block); err != nil {
+		log.Fatalf("failed to init secrets cipher: %v", err)
+	}
+}
+
+func encryptSecret(qualified string, value []byte) (string, error) {
+	if secretsAEAD == nil {
+		return "", errors.New("secrets are disabled")
+	}
+	nonce := make([]byte, secretsAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := secretsAEAD.Seal(nonce, nonce, value, []byte(qualified))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptSecret(qualified string) ([]byte, error) {
+	s := storedSecret{}
+	if err := kv.GetJSON(db, secretKey(qualified), &s); err != nil {
+		return nil, err
+	}
+	return decrypt(qualified, s.Data)
+}
+
+// decrypt - open value sealed by encryptSecret with the same name
+func decrypt(qualified, data string) ([]byte, error) {
+	if secretsAEAD == nil {
+		return nil, errors.New("secrets are disabled")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < secretsAEAD.NonceSize() {
+		return nil, fmt.Errorf("corrupted secret %v", qualified)
+	}
+	n := secretsAEAD.NonceSize()
+	return secretsAEAD.Open(nil, sealed[:n], sealed[n:], []byte(qualified))
+}
+
+// resolveSecrets - values of secrets referenced by task of service, decrypted only on dispatch
+func resolveSecrets(service string, refs []kv.SecretRef) (secrets []*pb.Secret) {
+	ns, _ := kv.SplitName(service)
+	for _, ref := range refs {
+		value, err := decryptSecret(kv.QualifiedName(ns, ref.Name))
+		if err != nil {
+			log.Printf("Failed to resolve secret %v of %v: %v", ref.Name, service, err)
+			continue
+		}
+		secrets = append(secrets, &pb.Secret{Name: ref.Name, Value: value, Env: ref.Env, File: ref.File})
+	}
+	return
+}
+
+// checkSecretRefs - error if service references missing secret or delivers it wrong
+func checkSecretRefs(ns string, refs []kv.SecretRef) error {
+	for _, ref := range refs {
+		if !kv.KeyExist(db, secretKey(kv.QualifiedName(ns, ref.Name))) {
+			return fmt.Errorf("unknown secret %v", ref.Name)
+		}
+		if strings.ContainsAny(ref.File, "/\\") || ref.File == ".." || strings.ContainsAny(ref.Env, "= ") {
+			return fmt.Errorf("wrong delivery of secret %v", ref.Name)
+		}
+	}
+	return nil
+}
+
+// secretUsers - services of namespace referencing secret in spec or in queued tasks
+func secretUsers(ns, name string) (services []string) {
+	for _, s := range namespaceServices(ns) {
+		for _, ref := range serviceSpec(s).Secrets {
+			if ref.Name == name {
+				services = append(services, s)
+			}
+		}
+	}
+	for _, t := range kv.TasksList(db) {
+		task := kv.Task{}
+		if err := kv.GetJSON(db, t, &task); err != nil || contains(services, task.Service) {
+			continue
+		}
+		if taskNs, _ := kv.SplitName(task.Service); taskNs != ns {
+			continue
+		}
+		for _, ref := range task.Secrets {
+			if ref.Name == name {
+				services = append(services, task.Service)
+				break
+			}
+		}
+	}
+	return
+}
+
+func secretCreate(c echo.Context) error {
+	return createSecret(c, kv.DefaultNamespace)
+}
+
+func nsSecretCreate(c echo.Context) error {
+	return createSecret(c, c.Param("ns"))
+}
+
+// createSecret - store or replace secret, running containers get new value when recreated
+func createSecret(c echo.Context, ns string) error {
+	req := secretRequest{}
+	err := c.Bind(&req)
+	name := kv.QualifiedName(ns, req.Name)
+	c.Set(auditTarget, name)
+	// value never gets to audit log
+	c.Set(auditPayload, fmt.Sprintf(`{"name":%q}`, req.Name))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	if secretsAEAD == nil {
+		return c.String(http.StatusServiceUnavailable, "Secrets are disabled, set -secrets-key")
+	}
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	if !validName.MatchString(req.Name) {
+		return c.String(http.StatusBadRequest, "Wrong secret name")
+	}
+	data, err := encryptSecret(name, []byte(req.Value))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	s := storedSecret{Name: req.Name, Namespace: ns, Created: time.Now()}
+	if old := (storedSecret{}); kv.GetJSON(db, secretKey(name), &old) == nil {
+		s.Created = old.Created
+	}
+	s.Data, s.Updated = data, time.Now()
+	if err := kv.PutJSON(db, secretKey(name), s); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	log.Printf("Secret %v stored", name)
+	s.Data = ""
+	return c.JSON(http.StatusOK, s)
+}
+
+func secretsList(c echo.Context) error {
+	return listSecrets(c, kv.DefaultNamespace)
+}
+
+func nsSecretsList(c echo.Context) error {
+	return listSecrets(c, c.Param("ns"))
+}
+
+// listSecrets - names and dates only, values are never returned
+func listSecrets(c echo.Context, ns string) error {
+	if !namespaceExists(ns) {
+		return c.String(http.StatusNotFound, "Unknown namespace")
+	}
+	secrets := []storedSecret{}
+	for _, k := range kv.KeysList(db, secretKey("")) {
+		s := storedSecret{}
+		if err := kv.GetJSON(db, k, &s); err != nil || s.Namespace != ns {
+			continue
+		}
+		s.Data = ""
+		secrets = append(secrets, s)
+	}
+	return c.JSON(http.StatusOK, secrets)
+}
+
+func secretDelete(c echo.Context) error {
+	return deleteSecret(c, kv.DefaultNamespace)
+}
+
+func nsSecretDelete(c echo.Context) error {
+	return deleteSecret(c, c.Param("ns"))
+}
+
+func deleteSecret(c echo.Context, ns string) error {
+	name := kv.QualifiedName(ns, c.Param("name"))
+	c.Set(auditTarget, name)
+	if !kv.KeyExist(db, secretKey(name)) {
+		return c.String(http.StatusNotFound, "Unknown secret")
+	}
+	if users := secretUsers(ns, c.Param("name")); len(users) > 0 {
+		return c.String(http.StatusConflict, fmt.Sprintf("Secret is used by %v", strings.Join(users, ", ")))
+	}
+	kv.DeleteKV(db, secretKey(name))
+	log.Printf("Secret %v deleted", name)
+	return c.NoContent(http.StatusNoContent)
+}
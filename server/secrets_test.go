@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSecretsKey - init secrets cipher from key file like -secrets-key does
+func testSecretsKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "key")
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	if err := ioutil.WriteFile(file, []byte(key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	*secretsKey = file
+	initSecrets()
+	t.Cleanup(func() {
+		*secretsKey = ""
+		secretsAEAD = nil
+	})
+}
+
+func TestEncryptSecret(t *testing.T) {
+	testSecretsKey(t)
+	sealed, err := encryptSecret("team.db", []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, base64.StdEncoding.EncodeToString([]byte("s3cret"))) {
+		t.Fatal("value is stored in plain text")
+	}
+	again, _ := encryptSecret("team.db", []byte("s3cret"))
+	if again == sealed {
+		t.Fatal("nonce is reused")
+	}
+	value, err := decrypt("team.db", sealed)
+	if err != nil || string(value) != "s3cret" {
+		t.Fatalf("decrypt = %q, %v", value, err)
+	}
+	// ciphertext is bound to secret name
+	if _, err := decrypt("other.db", sealed); err == nil {
+		t.Fatal("secret opened under other name")
+	}
+	data, _ := base64.StdEncoding.DecodeString(sealed)
+	data[len(data)-1] ^= 1
+	if _, err := decrypt("team.db", base64.StdEncoding.EncodeToString(data)); err == nil {
+		t.Fatal("tampered secret opened")
+	}
+	for _, corrupted := range []string{"not base64!", "AAAA"} {
+		if _, err := decrypt("team.db", corrupted); err == nil {
+			t.Fatalf("corrupted %q opened", corrupted)
+		}
+	}
+}
+
+func TestSecretsDisabled(t *testing.T) {
+	secretsAEAD = nil
+	if _, err := encryptSecret("db", []byte("x")); err == nil {
+		t.Fatal("secret encrypted without key")
+	}
+	if _, err := decrypt("db", "AAAA"); err == nil {
+		t.Fatal("secret decrypted without key")
+	}
+}
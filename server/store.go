@@ -0,0 +1,28 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+var stopEmbeddedEtcd = func() {}
+
+// openStore - open state store selected by -store flag
+func openStore() kv.Store {
+	switch *storeBackend {
+	case "bitcask":
+		return kv.InitDB(*dbPath)
+	case "etcd":
+		endpoints := strings.Split(*etcdEndpoints, ",")
+		if *etcdEmbed != "" {
+			endpoint, stop := kv.StartEmbeddedEtcd(filepath.Join(*etcdEmbed, "etcd"), "http://127.0.0.1:2379", "http://127.0.0.1:2380")
+			endpoints, stopEmbeddedEtcd = []string{endpoint}, stop
+			log.Printf("Embedded etcd started at %v", endpoint)
+		}
+		return kv.InitEtcd(endpoints)
+	}
+	log.Fatalf("unknown store backend %q", *storeBackend)
+	return nil
+}
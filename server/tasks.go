@@ -0,0 +1,181 @@
+package main
+
+import (
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// taskStatus - last phase of container task reported by agent
+type taskStatus struct {
+	Container string    `json:"container"`
+	Service   string    `json:"service"`
+	Node      string    `json:"node"`
+	Phase     string    `json:"phase"`
+	Detail    string    `json:"detail,omitempty"`
+	Digest    string    `json:"digest,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+var pullPolicies = map[string]bool{"": true, "always": true, "if-not-present": true, "never": true}
+
+// replacement - container replaced by recreate task, restored when task fails
+type replacement struct {
+	Old    string        `json:"old"`
+	Node   string        `json:"node"`
+	Record *kv.Container `json:"record,omitempty"`
+	Task   *kv.Task      `json:"task,omitempty"`
+}
+
+func taskStatusKey(container string) string {
+	return "TaskStatus-" + container
+}
+
+func replacementKey(container string) string {
+	return "Replacement-" + container
+}
+
+// recordTaskStatus - store phase of container task of reporting node, first digest pulled for service pins its image
+func recordTaskStatus(s *pb.TaskStatus) bool {
+	container := s.GetContainer()
+	if !kv.KeyExist(db, container) || !kv.InList(db, kv.NodeKey(s.GetNode()), container) {
+		return false
+	}
+	service := kv.ServiceName(container)
+	status := taskStatus{Container: container, Service: service, Node: s.GetNode(), Phase: s.GetPhase(),
+		Detail: s.GetDetail(), Digest: s.GetDigest(), Time: time.Unix(s.GetTimestamp(), 0)}
+	if err := kv.PutJSON(db, taskStatusKey(container), status); err != nil {
+		log.Printf("Failed to store task status of %v: %v", container, err)
+	}
+	switch status.Phase {
+	case "started":
+		kv.DeleteKV(db, replacementKey(container))
+		taskResults.WithLabelValues("started").Inc()
+		recordEvent("task-completed", service, status.Node, fmt.Sprintf("%v started", container))
+	case "failed":
+		taskResults.WithLabelValues("failed").Inc()
+		recordEvent("task-failed", service, status.Node, fmt.Sprintf("%v failed: %v", container, status.Detail))
+		log.Printf("Task of %v failed on %v: %v", container, status.Node, status.Detail)
+		if kv.KeyExist(db, replacementKey(container)) {
+			restoreReplaced(container)
+			return true
+		}
+	}
+	if status.Digest != "" {
+		pinDigest(service, status.Digest)
+	}
+	return true
+}
+
+// oldReported - container is still on node by reports of this leader or last stored inventory
+func oldReported(node, container string) bool {
+	if recent(node, container, *gcGrace) {
+		return true
+	}
+	for _, c := range nodeInventory(node).GetContainers() {
+		if c.GetName() == container {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreReplaced - undo failed recreate: old container still on node keeps its record, queued recreate task
+// is queued again and replica whose old container is gone gets new create task
+func restoreReplaced(container string) {
+	rep := replacement{}
+	restore := false
+	err := kv.Update(db, func(b *kv.Batch) error {
+		if err := b.GetJSON(replacementKey(container), &rep); err != nil {
+			return err
+		}
+		service := kv.ServiceName(container)
+		b.Delete(replacementKey(container))
+		b.Delete(container)
+		b.Eject(kv.NodeKey(rep.Node), container)
+		b.Eject(kv.ServiceKey(service), container)
+		restore = rep.Record != nil && oldReported(rep.Node, rep.Old)
+		switch {
+		case restore:
+			b.Append(kv.NodeKey(rep.Node), rep.Old)
+			b.Append(kv.ServiceKey(service), rep.Old)
+			b.PutJSON(rep.Old, rep.Record)
+		case rep.Task != nil:
+			task := *rep.Task
+			task.ID, task.Container = nameWithSuffix("Task"), nameWithSuffix(task.Service)
+			b.PutJSON(task.ID, task)
+		default:
+			task := createTask(service, batchSpec(b, service))
+			b.PutJSON(task.ID, task)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to restore %v replaced by %v: %v", rep.Old, container, err)
+		return
+	}
+	if restore {
+		log.Printf("Recreate of %v as %v failed, keeping old container", rep.Old, container)
+	} else {
+		log.Printf("Recreate of %v as %v failed, old container is gone, task queued again", rep.Old, container)
+	}
+}
+
+// pinDigest - keep replicas of service on the digest resolved by first pull of deploy
+func pinDigest(service, digest string) {
+	spec, pinned := svcConfig{}, false
+	err := kv.Update(db, func(b *kv.Batch) error {
+		pinned = false
+		if err := b.GetJSON(specKey(service), &spec); err != nil || spec.Digest != "" || strings.Contains(spec.Image, "@") {
+			return nil
+		}
+		spec.Digest, pinned = digest, true
+		return b.PutJSON(specKey(service), spec)
+	})
+	if err != nil {
+		log.Printf("Failed to pin %v to %v: %v", service, digest, err)
+		return
+	}
+	if pinned {
+		log.Printf("Service %v pinned to %v@%v", service, spec.Image, digest)
+	}
+}
+
+// pinnedImage - image reference of service task, pinned digest is added to its tag
+func pinnedImage(service, image string) string {
+	spec := serviceSpec(service)
+	if spec.Digest == "" || image != spec.Image {
+		return image
+	}
+	return image + "@" + spec.Digest
+}
+
+func containerTaskStatus(container string) *taskStatus {
+	status := taskStatus{}
+	if err := kv.GetJSON(db, taskStatusKey(container), &status); err != nil {
+		return nil
+	}
+	return &status
+}
+
+// tasks - last phases of container tasks, ?service= limits them to qualified service name
+func tasks(c echo.Context) error {
+	service := c.QueryParam("service")
+	statuses := []taskStatus{}
+	for _, k := range kv.KeysList(db, taskStatusKey("")) {
+		s := taskStatus{}
+		if err := kv.GetJSON(db, k, &s); err != nil || (service != "" && s.Service != service) {
+			continue
+		}
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Time.Before(statuses[j].Time) })
+	return c.JSON(http.StatusOK, statuses)
+}
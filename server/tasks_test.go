@@ -0,0 +1,62 @@
+package main
+
+import (
+	kv "dockerator/kvstore"
+	"testing"
+)
+
+// failedRecreate - web-...10 on n1 replaced by web-...20 whose task failed
+func failedRecreate(t *testing.T) (old, replaced string) {
+	old, replaced = "web-cb0j2nq7f0s8aqq0ab10", "web-cb0j2nq7f0s8aqq0ab20"
+	kv.PutJSON(db, specKey("web"), svcConfig{Name: "web", Image: "nginx", Replicas: 1})
+	kv.PutJSON(db, kv.NodeKey("n1"), []string{replaced})
+	kv.PutJSON(db, kv.ServiceKey("web"), []string{replaced})
+	kv.PutJSON(db, replaced, kv.Container{Image: "nginx:2", Replicas: 1})
+	kv.PutJSON(db, replacementKey(replaced), replacement{Old: old, Node: "n1", Record: &kv.Container{Image: "nginx:1", Replicas: 1}})
+	return
+}
+
+func TestRestoreReplacedKeepsReportedContainer(t *testing.T) {
+	testStore(t)
+	old, replaced := failedRecreate(t)
+	recordReport("n1", old, true)
+	t.Cleanup(func() { delete(reports.nodes, "n1") })
+	restoreReplaced(replaced)
+	rec := kv.Container{}
+	if err := kv.GetJSON(db, old, &rec); err != nil || rec.Image != "nginx:1" {
+		t.Fatalf("old record = %+v, %v", rec, err)
+	}
+	if !kv.InList(db, kv.NodeKey("n1"), old) || kv.InList(db, kv.ServiceKey("web"), replaced) || kv.KeyExist(db, replaced) {
+		t.Fatal("lists are not restored")
+	}
+	if len(kv.TasksList(db)) != 0 {
+		t.Fatal("task queued for restored replica")
+	}
+}
+
+func TestRestoreReplacedQueuesLostReplica(t *testing.T) {
+	testStore(t)
+	old, replaced := failedRecreate(t)
+	restoreReplaced(replaced)
+	if kv.KeyExist(db, old) || kv.KeyExist(db, replaced) || kv.KeyExist(db, replacementKey(replaced)) {
+		t.Fatal("records of gone containers are kept")
+	}
+	tasks := kv.TasksList(db)
+	task := kv.Task{}
+	if len(tasks) != 1 || kv.GetJSON(db, tasks[0], &task) != nil || task.Job != "create" || task.Service != "web" || task.Image != "nginx" {
+		t.Fatalf("tasks = %v, %+v", tasks, task)
+	}
+}
+
+func TestPinDigest(t *testing.T) {
+	testStore(t)
+	kv.PutJSON(db, specKey("web"), svcConfig{Name: "web", Image: "nginx", Replicas: 2})
+	pinDigest("web", "sha256:aa")
+	pinDigest("web", "sha256:bb")
+	if spec := serviceSpec("web"); spec.Digest != "sha256:aa" || spec.Replicas != 2 {
+		t.Fatalf("spec = %+v", spec)
+	}
+	if pinnedImage("web", "nginx") != "nginx@sha256:aa" {
+		t.Fatalf("pinned image = %v", pinnedImage("web", "nginx"))
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// nodeMD - gRPC metadata with node of call forwarded to leader
+const nodeMD = "x-dockerator-node"
+
+// joinToken - stored bootstrap token, secret part is kept only as hash
+type joinToken struct {
+	ID      string    `json:"id"`
+	Hash    string    `json:"hash,omitempty"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	Node    string    `json:"node,omitempty"`
+}
+
+// staticTokenID - token id recorded for nodes joined with -join-token
+const staticTokenID = "static"
+
+type tokenRequest struct {
+	TTL string `json:"ttl"`
+}
+
+func tokenKey(id string) string {
+	return "JoinToken-" + id
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("failed to read random: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// createToken - new "<id>.<secret>" token valid for ttl
+func createToken(ttl time.Duration) (token string, t joinToken, err error) {
+	id, secret := randomHex(3), randomHex(16)
+	t = joinToken{ID: id, Hash: hashSecret(secret), Created: time.Now(), Expires: time.Now().Add(ttl)}
+	err = kv.PutJSON(db, tokenKey(id), t)
+	return fmt.Sprintf("%v.%v", id, secret), t, err
+}
+
+// validToken - token is static one from flags or generated, not revoked and not expired
+func validToken(token string) bool {
+	if *staticJoinToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(*staticJoinToken)) == 1 {
+		return true
+	}
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !kv.KeyExist(db, tokenKey(parts[0])) {
+		return false
+	}
+	t := joinToken{}
+	if err := kv.GetJSON(db, tokenKey(parts[0]), &t); err != nil {
+		log.Printf("Failed to decode join token %v: %v", parts[0], err)
+		return false
+	}
+	return time.Now().Before(t.Expires) && subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(t.Hash)) == 1
+}
+
+// admitNode - id of valid join token allowed to join node: generated token is bound to first node joined with it,
+// registered node can join again only with token it joined with, e.g. after agent restart
+func admitNode(token, node string) (id string, err error) {
+	if !validToken(token) {
+		return "", errors.New("invalid join token")
+	}
+	id = staticTokenID
+	if *staticJoinToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(*staticJoinToken)) != 1 {
+		id = strings.SplitN(token, ".", 2)[0]
+	}
+	err = kv.Update(db, func(b *kv.Batch) error {
+		rec := nodeCert{}
+		known := b.GetJSON(nodeCertKey(node), &rec) == nil && !rec.Revoked
+		// nodes with certificates renewed before tokens were recorded keep the token they join with next
+		if (known && rec.Token != "" && rec.Token != id) || (!known && b.InList("Nodes", node)) {
+			return fmt.Errorf("node name %v is taken, remove node first", node)
+		}
+		if id == staticTokenID {
+			return nil
+		}
+		t := joinToken{}
+		if err := b.GetJSON(tokenKey(id), &t); err != nil {
+			return errors.New("invalid join token")
+		}
+		if t.Node != "" && t.Node != node {
+			return fmt.Errorf("join token is bound to node %v", t.Node)
+		}
+		if t.Node == "" {
+			t.Node = node
+			return b.PutJSON(tokenKey(id), t)
+		}
+		return nil
+	})
+	return
+}
+
+// callerNode - node verified by client certificate, or forwarded by cluster member which verified it
+func callerNode(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", errors.New("no peer")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return "", errors.New("client certificate required")
+	}
+	cert := info.State.VerifiedChains[0][0]
+	node, member, err := certificateIdentity(cert)
+	if err == nil && !member && certRevoked(node, cert) {
+		return node, errors.New("certificate is revoked")
+	}
+	if err != nil || !member {
+		return node, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(nodeMD); len(v) > 0 && v[0] != "" {
+		return v[0], nil
+	}
+	return "", errors.New("no forwarded node")
+}
+
+// nodeAuth - node identity is taken from certificate, only Join goes without one
+func nodeAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	// join token is checked by Join itself
+	if _, ok := req.(*pb.JoinRequest); ok {
+		return handler(ctx, req)
+	}
+	r, ok := req.(interface{ GetNode() string })
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unknown node")
+	}
+	node, err := callerNode(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if node != r.GetNode() {
+		log.Printf("Rejected call of %v by %v", r.GetNode(), node)
+		return nil, status.Errorf(codes.PermissionDenied, "certificate of %v can't act as %v", node, r.GetNode())
+	}
+	// certificate proves node was admitted, it may register again e.g. after state loss
+	if reg, ok := req.(*pb.Request); ok && reg.GetService() == "nodereg" {
+		return handler(ctx, req)
+	}
+	if !kv.InList(db, "Nodes", node) {
+		return nil, status.Error(codes.Unauthenticated, "unknown node")
+	}
+	return handler(ctx, req)
+}
+
+func tokenCreate(c echo.Context) error {
+	req := tokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Wrong JSON format")
+	}
+	ttl := *joinTokenTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			return c.String(http.StatusBadRequest, "Wrong ttl")
+		}
+		ttl = d
+	}
+	token, t, err := createToken(ttl)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	log.Printf("Join token %v created, expires %v", t.ID, t.Expires.Format(time.RFC3339))
+	return c.JSON(http.StatusOK, map[string]interface{}{"id": t.ID, "token": token, "expires": t.Expires})
+}
+
+func tokensList(c echo.Context) error {
+	tokens := []joinToken{}
+	for _, k := range kv.KeysList(db, tokenKey("")) {
+		t := joinToken{}
+		if err := kv.GetJSON(db, k, &t); err != nil {
+			log.Printf("Failed to decode join token %v: %v", k, err)
+			continue
+		}
+		t.Hash = ""
+		tokens = append(tokens, t)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func tokenRevoke(c echo.Context) error {
+	id := c.Param("id")
+	if !kv.KeyExist(db, tokenKey(id)) {
+		return c.String(http.StatusNotFound, "Unknown token")
+	}
+	if !kv.DeleteKV(db, tokenKey(id)) {
+		return c.String(http.StatusInternalServerError, "Failed to revoke token")
+	}
+	log.Printf("Join token %v revoked", id)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// expiredTokens - ids of join tokens past expiry
+func expiredTokens() (ids []string) {
+	for _, k := range kv.KeysList(db, tokenKey("")) {
+		t := joinToken{}
+		if err := kv.GetJSON(db, k, &t); err == nil && time.Now().After(t.Expires) {
+			ids = append(ids, t.ID)
+		}
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	pb "dockerator/dockerator"
+	kv "dockerator/kvstore"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdmitNodeBindsToken(t *testing.T) {
+	testStore(t)
+	token, tok, err := createToken(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := admitNode(token, "n1"); err != nil || id != tok.ID {
+		t.Fatalf("first join = %v, %v", id, err)
+	}
+	if _, err := admitNode(token, "n2"); err == nil || !strings.Contains(err.Error(), "bound to node n1") {
+		t.Fatalf("token used by other node: %v", err)
+	}
+	// agent restart joins again with the same token
+	if _, err := admitNode(token, "n1"); err != nil {
+		t.Fatalf("second join of n1: %v", err)
+	}
+	// registered node can't take other token
+	recordNodeCert("n1", tok.ID, "1")
+	kv.AppendKV(db, "Nodes", "n1")
+	other, _, _ := createToken(time.Hour)
+	if _, err := admitNode(other, "n1"); err == nil {
+		t.Fatal("registered node joined with other token")
+	}
+}
+
+func TestInvalidTokens(t *testing.T) {
+	testStore(t)
+	expired, _, _ := createToken(-time.Minute)
+	revoked, tok, _ := createToken(time.Hour)
+	kv.DeleteKV(db, tokenKey(tok.ID))
+	valid, _, _ := createToken(time.Hour)
+	id := strings.SplitN(valid, ".", 2)[0]
+	for name, token := range map[string]string{
+		"expired":      expired,
+		"revoked":      revoked,
+		"wrong secret": id + ".00",
+		"no secret":    id,
+		"empty":        "",
+	} {
+		if validToken(token) {
+			t.Errorf("%v token is valid", name)
+		}
+		if _, err := admitNode(token, "n1"); err == nil {
+			t.Errorf("%v token admitted node", name)
+		}
+	}
+	if !validToken(valid) {
+		t.Fatal("valid token rejected")
+	}
+}
+
+func TestStaticToken(t *testing.T) {
+	testStore(t)
+	*staticJoinToken = "static-secret"
+	defer func() { *staticJoinToken = "" }()
+	for _, node := range []string{"n1", "n2"} {
+		if id, err := admitNode("static-secret", node); err != nil || id != staticTokenID {
+			t.Fatalf("join of %v = %v, %v", node, id, err)
+		}
+	}
+	if _, err := admitNode("static-other", "n3"); err == nil {
+		t.Fatal("wrong static token admitted node")
+	}
+}
+
+// peerContext - context of gRPC call made with verified client certificate
+func peerContext(cert *x509.Certificate) context.Context {
+	state := tls.ConnectionState{}
+	if cert != nil {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestNodeAuth(t *testing.T) {
+	testCA(t)
+	n1 := nodeCertificate(t, "n1", "t1")
+	kv.AppendKV(db, "Nodes", "n1")
+	unknown := nodeCertificate(t, "n2", "t2")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "handled", nil
+	}
+	for _, c := range []struct {
+		name string
+		cert *x509.Certificate
+		req  interface{}
+		code codes.Code
+	}{
+		{"registered node", n1, &pb.Request{Node: "n1", Service: "nodestatus"}, codes.OK},
+		{"other node", n1, &pb.Request{Node: "n2", Service: "nodestatus"}, codes.PermissionDenied},
+		{"unknown node", unknown, &pb.Request{Node: "n2", Service: "nodestatus"}, codes.Unauthenticated},
+		{"unknown node registers", unknown, &pb.Request{Node: "n2", Service: "nodereg"}, codes.OK},
+		{"no certificate", nil, &pb.Request{Node: "n1", Service: "nodestatus"}, codes.Unauthenticated},
+		{"join without certificate", nil, &pb.JoinRequest{Node: "n3"}, codes.OK},
+	} {
+		_, err := nodeAuth(peerContext(c.cert), c.req, &grpc.UnaryServerInfo{}, handler)
+		if status.Code(err) != c.code {
+			t.Errorf("%v: %v, want %v", c.name, err, c.code)
+		}
+	}
+
+	// removed node is rejected even if it is listed again
+	kv.PutJSON(db, nodeCertKey("n1"), nodeCert{Revoked: true})
+	if _, err := nodeAuth(peerContext(n1), &pb.Request{Node: "n1", Service: "nodestatus"}, &grpc.UnaryServerInfo{}, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("revoked certificate: %v", err)
+	}
+}